@@ -0,0 +1,166 @@
+package client
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/thoom/gulp/config"
+)
+
+// BuildAuth merges the -u, -bearer and -api-key flag values over the auth configuration.
+// Flag values can use the same ${VAR} and $(file:path) references as the configuration.
+func BuildAuth(user, bearer, apiKey string, authConfig config.Auth) (config.Auth, error) {
+	auth := authConfig
+	if authConfig.APIKey != nil {
+		key := *authConfig.APIKey
+		auth.APIKey = &key
+	}
+
+	var err error
+	if user != "" {
+		if user, err = config.Interpolate(user); err != nil {
+			return auth, err
+		}
+
+		// A username from the command line replaces any configured credentials
+		auth.Username, auth.Password, _ = strings.Cut(user, ":")
+		auth.Bearer = ""
+	}
+
+	if bearer != "" {
+		if auth.Bearer, err = config.Interpolate(bearer); err != nil {
+			return auth, err
+		}
+
+		if user == "" {
+			auth.Username, auth.Password, auth.Digest = "", "", nil
+		}
+	}
+
+	if apiKey != "" {
+		if auth.APIKey, err = parseAPIKey(apiKey); err != nil {
+			return auth, err
+		}
+	}
+
+	if auth.UseBasic() && auth.Bearer != "" {
+		return auth, fmt.Errorf("auth can use either a username/password or a bearer token, not both")
+	}
+
+	if auth.UseDigest() && !auth.UseBasic() {
+		return auth, fmt.Errorf("digest auth needs a username and password")
+	}
+
+	if auth.APIKey != nil {
+		if auth.APIKey.Name == "" {
+			return auth, fmt.Errorf("the API key needs a name")
+		}
+
+		if auth.APIKey.In != "" && auth.APIKey.In != "header" && auth.APIKey.In != config.APIKeyInQuery {
+			return auth, fmt.Errorf("invalid API key location '%s', expected 'header' or 'query'", auth.APIKey.In)
+		}
+	}
+
+	return auth, nil
+}
+
+// parseAPIKey parses a name=value API key, with an optional @header or @query suffix
+func parseAPIKey(value string) (*config.APIKey, error) {
+	resolved, err := config.Interpolate(value)
+	if err != nil {
+		return nil, err
+	}
+
+	name, key, ok := strings.Cut(resolved, "=")
+	if !ok || strings.TrimSpace(name) == "" {
+		return nil, fmt.Errorf("invalid API key '%s', expected name=value[@header|@query]", value)
+	}
+
+	apiKey := &config.APIKey{Name: strings.TrimSpace(name), Value: key}
+	if i := strings.LastIndex(key, "@"); i >= 0 {
+		if in := key[i+1:]; in == "header" || in == config.APIKeyInQuery {
+			apiKey.Value = key[:i]
+			apiKey.In = in
+		}
+	}
+
+	return apiKey, nil
+}
+
+// AuthHeaders returns the Authorization header and the API key header, if any
+func AuthHeaders(auth config.Auth) http.Header {
+	headers := make(http.Header)
+	switch {
+	case auth.UseDigest():
+		// Digest credentials are only sent once the server asks for them (see DigestAuth)
+	case auth.UseBasic():
+		headers.Set("Authorization", "Basic "+basicCredentials(auth))
+	case auth.Bearer != "":
+		headers.Set("Authorization", "Bearer "+auth.Bearer)
+	}
+
+	if auth.APIKey != nil && auth.APIKey.In != config.APIKeyInQuery {
+		headers.Set(auth.APIKey.Name, auth.APIKey.Value)
+	}
+
+	return headers
+}
+
+// WithAuthHeaders returns the configured headers with the auth headers set over them
+func WithAuthHeaders(configHeaders config.HeaderMap, auth config.Auth) http.Header {
+	headers := make(http.Header)
+	for k, v := range configHeaders {
+		headers[http.CanonicalHeaderKey(k)] = append([]string(nil), v...)
+	}
+
+	for k, v := range AuthHeaders(auth) {
+		headers[k] = v
+	}
+
+	return headers
+}
+
+// AuthQuery adds the API key to the URL's query string when it is sent as a query parameter
+func AuthQuery(rawURL string, auth config.Auth) (string, error) {
+	if auth.APIKey == nil || auth.APIKey.In != config.APIKeyInQuery {
+		return rawURL, nil
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid URL '%s': %s", rawURL, err)
+	}
+
+	query := u.Query()
+	query.Set(auth.APIKey.Name, auth.APIKey.Value)
+	u.RawQuery = query.Encode()
+
+	return u.String(), nil
+}
+
+// AuthSecrets returns the values that are masked when the request is displayed
+func AuthSecrets(auth config.Auth) []string {
+	var secrets []string
+	if auth.UseBasic() {
+		secrets = append(secrets, basicCredentials(auth))
+	}
+
+	for _, secret := range []string{auth.Password, auth.Bearer} {
+		if secret != "" {
+			secrets = append(secrets, secret)
+		}
+	}
+
+	if auth.APIKey != nil && auth.APIKey.Value != "" {
+		secrets = append(secrets, auth.APIKey.Value, url.QueryEscape(auth.APIKey.Value))
+	}
+
+	return secrets
+}
+
+func basicCredentials(auth config.Auth) string {
+	return base64.StdEncoding.EncodeToString([]byte(auth.Username + ":" + auth.Password))
+}
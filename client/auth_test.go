@@ -0,0 +1,175 @@
+package client
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/thoom/gulp/config"
+)
+
+func TestBuildAuthConfig(t *testing.T) {
+	assert := assert.New(t)
+
+	auth, err := BuildAuth("", "", "", config.Auth{Username: "admin", Password: "s3cret"})
+	assert.Nil(err)
+	assert.Equal(config.Auth{Username: "admin", Password: "s3cret"}, auth)
+}
+
+func TestBuildAuthUserFlag(t *testing.T) {
+	assert := assert.New(t)
+
+	// The password is split on the first colon
+	auth, err := BuildAuth("admin:s3c:ret", "", "", config.Auth{Bearer: "abc123def"})
+	assert.Nil(err)
+	assert.Equal(config.Auth{Username: "admin", Password: "s3c:ret"}, auth)
+
+	auth, err = BuildAuth("admin", "", "", config.Auth{Username: "other", Password: "s3cret"})
+	assert.Nil(err)
+	assert.Equal(config.Auth{Username: "admin"}, auth)
+}
+
+func TestBuildAuthBearerFlag(t *testing.T) {
+	assert := assert.New(t)
+
+	auth, err := BuildAuth("", "abc123def", "", config.Auth{Username: "admin", Password: "s3cret"})
+	assert.Nil(err)
+	assert.Equal(config.Auth{Bearer: "abc123def"}, auth)
+}
+
+func TestBuildAuthFlagReferences(t *testing.T) {
+	assert := assert.New(t)
+	t.Setenv("GULP_TEST_TOKEN", "abc123def")
+
+	auth, err := BuildAuth("", "${GULP_TEST_TOKEN}", "key=${GULP_TEST_TOKEN}", config.Auth{})
+	assert.Nil(err)
+	assert.Equal("abc123def", auth.Bearer)
+	assert.Equal("abc123def", auth.APIKey.Value)
+
+	_, err = BuildAuth("", "${GULP_TEST_MISSING}", "", config.Auth{})
+	assert.NotNil(err)
+}
+
+func TestBuildAuthBasicAndBearer(t *testing.T) {
+	assert := assert.New(t)
+
+	_, err := BuildAuth("admin:s3cret", "abc123def", "", config.Auth{})
+	assert.Equal("auth can use either a username/password or a bearer token, not both", fmt.Sprintf("%s", err))
+
+	_, err = BuildAuth("", "", "", config.Auth{Username: "admin", Bearer: "abc123def"})
+	assert.NotNil(err)
+}
+
+func TestBuildAuthAPIKeyFlag(t *testing.T) {
+	assert := assert.New(t)
+
+	auth, err := BuildAuth("", "", "X-Api-Key=abc123", config.Auth{})
+	assert.Nil(err)
+	assert.Equal(&config.APIKey{Name: "X-Api-Key", Value: "abc123"}, auth.APIKey)
+
+	auth, err = BuildAuth("", "", "key=abc=123@query", config.Auth{})
+	assert.Nil(err)
+	assert.Equal(&config.APIKey{Name: "key", Value: "abc=123", In: "query"}, auth.APIKey)
+
+	auth, err = BuildAuth("", "", "X-Api-Key=abc@123@header", config.Auth{})
+	assert.Nil(err)
+	assert.Equal(&config.APIKey{Name: "X-Api-Key", Value: "abc@123", In: "header"}, auth.APIKey)
+
+	// Anything else after the @ is part of the value
+	auth, err = BuildAuth("", "", "X-Api-Key=abc@123", config.Auth{})
+	assert.Nil(err)
+	assert.Equal(&config.APIKey{Name: "X-Api-Key", Value: "abc@123"}, auth.APIKey)
+
+	_, err = BuildAuth("", "", "abc123", config.Auth{})
+	assert.Equal("invalid API key 'abc123', expected name=value[@header|@query]", fmt.Sprintf("%s", err))
+}
+
+func TestBuildAuthAPIKeyConfig(t *testing.T) {
+	assert := assert.New(t)
+
+	authConfig := config.Auth{APIKey: &config.APIKey{Name: "X-Api-Key", Value: "abc123"}}
+	auth, err := BuildAuth("", "", "", authConfig)
+	assert.Nil(err)
+	assert.Equal(authConfig, auth)
+
+	// The configuration isn't changed
+	auth.APIKey.Value = "changed"
+	assert.Equal("abc123", authConfig.APIKey.Value)
+
+	_, err = BuildAuth("", "", "", config.Auth{APIKey: &config.APIKey{Value: "abc123"}})
+	assert.Equal("the API key needs a name", fmt.Sprintf("%s", err))
+
+	_, err = BuildAuth("", "", "", config.Auth{APIKey: &config.APIKey{Name: "key", Value: "abc123", In: "body"}})
+	assert.Equal("invalid API key location 'body', expected 'header' or 'query'", fmt.Sprintf("%s", err))
+}
+
+func TestAuthHeaders(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Empty(AuthHeaders(config.Auth{}))
+	assert.Equal(http.Header{"Authorization": {"Basic YWRtaW46czNjcmV0"}}, AuthHeaders(config.Auth{Username: "admin", Password: "s3cret"}))
+	assert.Equal(http.Header{"Authorization": {"Bearer abc123def"}, "X-Api-Key": {"abc123"}}, AuthHeaders(config.Auth{
+		Bearer: "abc123def",
+		APIKey: &config.APIKey{Name: "X-Api-Key", Value: "abc123"},
+	}))
+	assert.Empty(AuthHeaders(config.Auth{APIKey: &config.APIKey{Name: "key", Value: "abc123", In: "query"}}))
+}
+
+func TestWithAuthHeaders(t *testing.T) {
+	assert := assert.New(t)
+
+	configHeaders := config.HeaderMap{"authorization": {"Bearer old"}, "X-Team": {"core", "platform"}}
+	headers := WithAuthHeaders(configHeaders, config.Auth{Bearer: "abc123def"})
+	assert.Equal(http.Header{"Authorization": {"Bearer abc123def"}, "X-Team": {"core", "platform"}}, headers)
+	assert.Equal(config.StringList{"Bearer old"}, configHeaders["authorization"])
+
+	// Headers from the -H flag replace the auth headers
+	built, err := BuildHeaders([]string{"Authorization: Bearer xyz"}, headers, "")
+	assert.Nil(err)
+	assert.Equal("Bearer xyz", built.Get("Authorization"))
+}
+
+func TestAuthQuery(t *testing.T) {
+	assert := assert.New(t)
+
+	u, err := AuthQuery("https://api.ex.io/users?page=2", config.Auth{APIKey: &config.APIKey{Name: "api_key", Value: "abc/123", In: "query"}})
+	assert.Nil(err)
+	assert.Equal("https://api.ex.io/users?api_key=abc%2F123&page=2", u)
+
+	u, err = AuthQuery("https://api.ex.io/users", config.Auth{APIKey: &config.APIKey{Name: "X-Api-Key", Value: "abc123"}})
+	assert.Nil(err)
+	assert.Equal("https://api.ex.io/users", u)
+
+	_, err = AuthQuery("https://api.ex.io/%zz", config.Auth{APIKey: &config.APIKey{Name: "key", Value: "abc123", In: "query"}})
+	assert.NotNil(err)
+}
+
+func TestAuthSecrets(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Empty(AuthSecrets(config.Auth{}))
+	assert.Equal([]string{"YWRtaW46czNjcmV0", "s3cret"}, AuthSecrets(config.Auth{Username: "admin", Password: "s3cret"}))
+	assert.Equal([]string{"abc123def", "abc/123", "abc%2F123"}, AuthSecrets(config.Auth{
+		Bearer: "abc123def",
+		APIKey: &config.APIKey{Name: "key", Value: "abc/123", In: "query"},
+	}))
+}
+
+func TestBuildAuthDigest(t *testing.T) {
+	assert := assert.New(t)
+
+	auth, err := BuildAuth("admin:s3cret", "", "", config.Auth{Digest: config.NewBool(true)})
+	assert.Nil(err)
+	assert.True(auth.UseDigest())
+	assert.Empty(AuthHeaders(auth))
+
+	_, err = BuildAuth("", "", "", config.Auth{Digest: config.NewBool(true)})
+	assert.Equal("digest auth needs a username and password", fmt.Sprintf("%s", err))
+
+	// A bearer token from the command line replaces the configured digest credentials
+	auth, err = BuildAuth("", "abc123def", "", config.Auth{Username: "admin", Password: "s3cret", Digest: config.NewBool(true)})
+	assert.Nil(err)
+	assert.False(auth.UseDigest())
+	assert.Equal(http.Header{"Authorization": {"Bearer abc123def"}}, AuthHeaders(auth))
+}
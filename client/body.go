@@ -0,0 +1,122 @@
+package client
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// BodyContentType detects the Content-Type of a body file from its extension.
+// YAML files are sent as JSON since the body is converted, and unknown files are sent as binary.
+func BodyContentType(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json", ".yml", ".yaml":
+		return "application/json"
+	case ".xml":
+		return "application/xml"
+	}
+
+	if contentType := mime.TypeByExtension(filepath.Ext(path)); contentType != "" {
+		return contentType
+	}
+
+	return "application/octet-stream"
+}
+
+// ReadBodyFile reads the body from the file, returning it along with the Content-Type detected from its extension
+func ReadBodyFile(path string) ([]byte, string, error) {
+	body, err := os.ReadFile(path)
+	if err != nil {
+		return nil, "", fmt.Errorf("could not read the body file: %s", err)
+	}
+
+	return body, BodyContentType(path), nil
+}
+
+// DataBody builds the body from the -d values, returning it along with its Content-Type.
+// A single @path value reads the file and a single JSON (or YAML flow) object or list is sent as JSON.
+// Anything else is URL encoded form data, sent as it's passed with the values joined by &.
+func DataBody(data []string) ([]byte, string, error) {
+	if len(data) == 1 {
+		if path, ok := strings.CutPrefix(data[0], "@"); ok {
+			return ReadBodyFile(path)
+		}
+
+		if trimmed := strings.TrimSpace(data[0]); strings.HasPrefix(trimmed, "{") || strings.HasPrefix(trimmed, "[") {
+			return []byte(data[0]), "application/json", nil
+		}
+	}
+
+	for _, d := range data {
+		if strings.HasPrefix(d, "@") {
+			return nil, "", fmt.Errorf("-d %s can't be combined with other -d values", d)
+		}
+	}
+
+	return []byte(strings.Join(data, "&")), FormContentType, nil
+}
+
+// BodyStream is a request body that's read as it's sent with chunked transfer encoding, so that it doesn't
+// have to fit in memory. A file is opened again for each request, stdin can only be read once.
+type BodyStream struct {
+	path  string
+	stdin io.Reader
+
+	mu   sync.Mutex
+	used bool
+}
+
+// NewFileStream streams the file, making sure it can be read before any requests are sent
+func NewFileStream(path string) (*BodyStream, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read the body file: %s", err)
+	}
+	f.Close()
+
+	return &BodyStream{path: path}, nil
+}
+
+// NewStdinStream streams the reader, usually stdin
+func NewStdinStream(stdin io.Reader) *BodyStream {
+	return &BodyStream{stdin: stdin}
+}
+
+// ContentType detects the Content-Type of a file from its extension like BodyContentType, except that YAML
+// is sent as it is since streamed bodies aren't converted to JSON. Stdin is sent as binary.
+func (s *BodyStream) ContentType() string {
+	if ext := strings.ToLower(filepath.Ext(s.path)); ext == ".yml" || ext == ".yaml" {
+		return "application/yaml"
+	}
+
+	return BodyContentType(s.path)
+}
+
+// Replayable reports whether the body can be sent more than once, ie. for repeated requests or redirects
+func (s *BodyStream) Replayable() bool {
+	return s.path != ""
+}
+
+// Open returns the body to send
+func (s *BodyStream) Open() (io.ReadCloser, error) {
+	if s.path != "" {
+		f, err := os.Open(s.path)
+		if err != nil {
+			return nil, fmt.Errorf("could not read the body file: %s", err)
+		}
+		return f, nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.used {
+		return nil, fmt.Errorf("the body streamed from stdin can only be sent once")
+	}
+	s.used = true
+
+	return io.NopCloser(s.stdin), nil
+}
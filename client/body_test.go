@@ -0,0 +1,119 @@
+package client
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBodyContentType(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("application/json", BodyContentType("user.json"))
+	assert.Equal("application/json", BodyContentType("user.YML"))
+	assert.Equal("application/json", BodyContentType("dir/user.yaml"))
+	assert.Equal("application/xml", BodyContentType("user.xml"))
+	assert.Equal("image/png", BodyContentType("me.png"))
+	assert.Equal("application/octet-stream", BodyContentType("user.dat"))
+	assert.Equal("application/octet-stream", BodyContentType("user"))
+}
+
+func TestReadBodyFile(t *testing.T) {
+	assert := assert.New(t)
+
+	file := filepath.Join(t.TempDir(), "user.xml")
+	os.WriteFile(file, []byte("<user/>"), 0644)
+
+	body, contentType, err := ReadBodyFile(file)
+	assert.Nil(err)
+	assert.Equal("<user/>", string(body))
+	assert.Equal("application/xml", contentType)
+
+	_, _, err = ReadBodyFile(filepath.Join(t.TempDir(), "missing.json"))
+	assert.Contains(fmt.Sprintf("%s", err), "could not read the body file:")
+}
+
+func TestDataBody(t *testing.T) {
+	assert := assert.New(t)
+
+	body, contentType, err := DataBody([]string{"name=gulp", "tag=a%20b"})
+	assert.Nil(err)
+	assert.Equal("name=gulp&tag=a%20b", string(body))
+	assert.Equal(FormContentType, contentType)
+
+	body, contentType, err = DataBody([]string{` {"name": "gulp"}`})
+	assert.Nil(err)
+	assert.Equal(` {"name": "gulp"}`, string(body))
+	assert.Equal("application/json", contentType)
+
+	body, contentType, err = DataBody([]string{"[1, 2]"})
+	assert.Nil(err)
+	assert.Equal("[1, 2]", string(body))
+	assert.Equal("application/json", contentType)
+
+	// Values containing a colon are still form data
+	body, contentType, err = DataBody([]string{"msg=hi: there"})
+	assert.Nil(err)
+	assert.Equal("msg=hi: there", string(body))
+	assert.Equal(FormContentType, contentType)
+}
+
+func TestDataBodyFile(t *testing.T) {
+	assert := assert.New(t)
+
+	file := filepath.Join(t.TempDir(), "user.yml")
+	os.WriteFile(file, []byte("name: gulp\n"), 0644)
+
+	body, contentType, err := DataBody([]string{"@" + file})
+	assert.Nil(err)
+	assert.Equal("name: gulp\n", string(body))
+	assert.Equal("application/json", contentType)
+
+	_, _, err = DataBody([]string{"name=gulp", "@" + file})
+	assert.Equal(fmt.Sprintf("-d @%s can't be combined with other -d values", file), fmt.Sprintf("%s", err))
+}
+
+func TestFileStream(t *testing.T) {
+	assert := assert.New(t)
+
+	file := filepath.Join(t.TempDir(), "upload.yml")
+	os.WriteFile(file, []byte("name: gulp\n"), 0644)
+
+	stream, err := NewFileStream(file)
+	assert.Nil(err)
+	assert.True(stream.Replayable())
+	assert.Equal("application/yaml", stream.ContentType())
+
+	// The file is read again for each request
+	for i := 0; i < 2; i++ {
+		body, err := stream.Open()
+		assert.Nil(err)
+		dat, _ := io.ReadAll(body)
+		body.Close()
+		assert.Equal("name: gulp\n", string(dat))
+	}
+
+	_, err = NewFileStream(filepath.Join(t.TempDir(), "missing.bin"))
+	assert.Contains(fmt.Sprintf("%s", err), "could not read the body file:")
+}
+
+func TestStdinStream(t *testing.T) {
+	assert := assert.New(t)
+
+	stream := NewStdinStream(strings.NewReader("line\r\n"))
+	assert.False(stream.Replayable())
+	assert.Equal("application/octet-stream", stream.ContentType())
+
+	body, err := stream.Open()
+	assert.Nil(err)
+	dat, _ := io.ReadAll(body)
+	assert.Equal("line\r\n", string(dat))
+
+	_, err = stream.Open()
+	assert.Equal("the body streamed from stdin can only be sent once", fmt.Sprintf("%s", err))
+}
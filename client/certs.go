@@ -0,0 +1,177 @@
+package client
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/thoom/gulp/config"
+	"github.com/youmark/pkcs8"
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+// ErrPassphraseRequired is returned when a PKCS#12 file or encrypted key needs a passphrase that wasn't given
+var ErrPassphraseRequired = errors.New("a passphrase is required")
+
+// errIncorrectPassphrase is returned by the decoders when the passphrase doesn't decrypt the data
+var errIncorrectPassphrase = errors.New("incorrect passphrase")
+
+// PassphrasePrompt asks for the passphrase of a client cert or key when the configured one doesn't work.
+// It's nil when nobody can answer, ie. stdin isn't a terminal.
+var PassphrasePrompt func(name string) (string, error)
+
+var (
+	// promptedPassphrases are remembered so that repeated requests and redirects only ask once
+	promptedPassphrases   = map[string]string{}
+	promptedPassphrasesMu sync.Mutex
+)
+
+// loadClientCert loads a PKCS#12 bundle or a PEM cert and key pair, decrypting an encrypted PKCS#8 key
+func loadClientCert(clientCert config.ClientAuth) (tls.Certificate, error) {
+	certData := strings.TrimSpace(clientCert.Cert)
+	if clientCert.IsPKCS12() {
+		return loadPKCS12(certData, clientCert.Passphrase)
+	}
+
+	keyData := strings.TrimSpace(clientCert.Key)
+	certInline, keyInline := strings.HasPrefix(certData, "-----BEGIN"), strings.HasPrefix(keyData, "-----BEGIN")
+	if certInline != keyInline {
+		return tls.Certificate{}, fmt.Errorf("client certificate and key must both be either file paths or inline PEM content, not mixed")
+	}
+
+	certPEM, keyPEM, keyName := []byte(certData), []byte(keyData), "the client key"
+	if !certInline {
+		var err error
+		if certPEM, err = os.ReadFile(certData); err != nil {
+			return tls.Certificate{}, fmt.Errorf("invalid client cert/key: %s", err)
+		}
+
+		if keyPEM, err = os.ReadFile(keyData); err != nil {
+			return tls.Certificate{}, fmt.Errorf("invalid client cert/key: %s", err)
+		}
+		keyName = keyData
+	}
+
+	keyPEM, err := decryptKey(keyPEM, keyName, clientCert.Passphrase)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("invalid client cert/key: %w", err)
+	}
+
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("invalid client cert/key: %s", err)
+	}
+
+	return cert, nil
+}
+
+// loadPKCS12 decodes the cert, key and any intermediate certs in a .p12/.pfx file
+func loadPKCS12(file, passphrase string) (tls.Certificate, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("invalid client cert/key: %s", err)
+	}
+
+	var cert tls.Certificate
+	err = withPassphrase(file, passphrase, func(passphrase string) error {
+		key, leaf, chain, err := pkcs12.DecodeChain(data, passphrase)
+		if errors.Is(err, pkcs12.ErrIncorrectPassword) {
+			return errIncorrectPassphrase
+		}
+		if err != nil {
+			return err
+		}
+
+		cert = tls.Certificate{Certificate: [][]byte{leaf.Raw}, PrivateKey: key, Leaf: leaf}
+		for _, ca := range chain {
+			cert.Certificate = append(cert.Certificate, ca.Raw)
+		}
+		return nil
+	})
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("invalid client cert/key: %w", err)
+	}
+
+	return cert, nil
+}
+
+// decryptKey replaces an ENCRYPTED PRIVATE KEY block with the decrypted PKCS#8 key.
+// Other keys are returned as is.
+func decryptKey(keyPEM []byte, name, passphrase string) ([]byte, error) {
+	block, _ := pem.Decode(keyPEM)
+	if block == nil || block.Type != "ENCRYPTED PRIVATE KEY" {
+		return keyPEM, nil
+	}
+
+	var der []byte
+	err := withPassphrase(name, passphrase, func(passphrase string) error {
+		// Without a passphrase the package would parse the key as if it wasn't encrypted
+		if passphrase == "" {
+			return errIncorrectPassphrase
+		}
+
+		key, err := pkcs8.ParsePKCS8PrivateKey(block.Bytes, []byte(passphrase))
+		if err != nil {
+			// AES-GCM keys fail authentication rather than parsing
+			if strings.Contains(err.Error(), "incorrect password") || strings.Contains(err.Error(), "authentication failed") {
+				return errIncorrectPassphrase
+			}
+			return err
+		}
+
+		der, err = x509.MarshalPKCS8PrivateKey(key)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+}
+
+// withPassphrase decodes with the configured passphrase, asking for one with PassphrasePrompt
+// if nothing was configured and it didn't work
+func withPassphrase(name, passphrase string, decode func(passphrase string) error) error {
+	if passphrase != "" {
+		if err := decode(passphrase); !errors.Is(err, errIncorrectPassphrase) {
+			return err
+		}
+
+		return fmt.Errorf("incorrect passphrase for '%s'", name)
+	}
+
+	promptedPassphrasesMu.Lock()
+	defer promptedPassphrasesMu.Unlock()
+
+	// Try a passphrase that was already asked for, or none since PKCS#12 files often don't have one
+	if prompted, ok := promptedPassphrases[name]; ok {
+		passphrase = prompted
+	}
+	if err := decode(passphrase); !errors.Is(err, errIncorrectPassphrase) {
+		return err
+	}
+
+	if PassphrasePrompt == nil {
+		return fmt.Errorf("%w for '%s', set client_auth.passphrase or -client-cert-passphrase", ErrPassphraseRequired, name)
+	}
+
+	prompted, err := PassphrasePrompt(name)
+	if err != nil {
+		return err
+	}
+
+	if err := decode(prompted); err != nil {
+		if errors.Is(err, errIncorrectPassphrase) {
+			return fmt.Errorf("incorrect passphrase for '%s'", name)
+		}
+		return err
+	}
+
+	promptedPassphrases[name] = prompted
+	return nil
+}
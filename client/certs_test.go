@@ -0,0 +1,178 @@
+package client
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/thoom/gulp/config"
+	"github.com/youmark/pkcs8"
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+// testClientCert creates a self-signed cert and its key
+func testClientCert(t *testing.T) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "gulp test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return cert, key
+}
+
+func writeP12(t *testing.T, passphrase string) (string, *x509.Certificate) {
+	cert, key := testClientCert(t)
+	data, err := pkcs12.Modern.Encode(key, cert, nil, passphrase)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file := filepath.Join(t.TempDir(), "client.p12")
+	os.WriteFile(file, data, 0600)
+	return file, cert
+}
+
+func encryptedPEMPair(t *testing.T, passphrase string) (string, string) {
+	cert, key := testClientCert(t)
+	der, err := pkcs8.MarshalPrivateKey(key, []byte(passphrase), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "ENCRYPTED PRIVATE KEY", Bytes: der})
+	return string(certPEM), string(keyPEM)
+}
+
+// withPrompt replaces the passphrase prompt and clears the remembered passphrases for the test
+func withPrompt(t *testing.T, prompt func(string) (string, error)) {
+	original := PassphrasePrompt
+	PassphrasePrompt = prompt
+	promptedPassphrases = map[string]string{}
+	t.Cleanup(func() {
+		PassphrasePrompt = original
+		promptedPassphrases = map[string]string{}
+	})
+}
+
+func TestLoadClientCertPKCS12(t *testing.T) {
+	assert := assert.New(t)
+	withPrompt(t, nil)
+
+	file, cert := writeP12(t, "s3cret")
+	loaded, err := loadClientCert(config.ClientAuth{Cert: file, Passphrase: "s3cret"})
+	assert.Nil(err)
+	assert.Equal(cert.Raw, loaded.Leaf.Raw)
+	assert.NotNil(loaded.PrivateKey)
+
+	_, err = loadClientCert(config.ClientAuth{Cert: file, Passphrase: "wrong"})
+	assert.NotNil(err)
+	assert.Contains(err.Error(), "incorrect passphrase for '"+file+"'")
+
+	_, err = loadClientCert(config.ClientAuth{Cert: file})
+	assert.True(errors.Is(err, ErrPassphraseRequired))
+}
+
+func TestLoadClientCertPKCS12WithoutPassphrase(t *testing.T) {
+	assert := assert.New(t)
+	withPrompt(t, func(string) (string, error) {
+		t.Fatal("the prompt should not be used")
+		return "", nil
+	})
+
+	file, _ := writeP12(t, "")
+	_, err := loadClientCert(config.ClientAuth{Cert: file})
+	assert.Nil(err)
+}
+
+func TestLoadClientCertPrompt(t *testing.T) {
+	assert := assert.New(t)
+
+	prompts := 0
+	withPrompt(t, func(name string) (string, error) {
+		prompts++
+		return "s3cret", nil
+	})
+
+	file, _ := writeP12(t, "s3cret")
+	for i := 0; i < 2; i++ {
+		_, err := loadClientCert(config.ClientAuth{Cert: file})
+		assert.Nil(err)
+	}
+
+	// The answer is remembered for the next requests
+	assert.Equal(1, prompts)
+}
+
+func TestLoadClientCertPromptIncorrect(t *testing.T) {
+	assert := assert.New(t)
+	withPrompt(t, func(string) (string, error) {
+		return "wrong", nil
+	})
+
+	file, _ := writeP12(t, "s3cret")
+	_, err := loadClientCert(config.ClientAuth{Cert: file})
+	assert.NotNil(err)
+	assert.Contains(err.Error(), "incorrect passphrase")
+	assert.Empty(promptedPassphrases)
+}
+
+func TestLoadClientCertEncryptedKey(t *testing.T) {
+	assert := assert.New(t)
+	withPrompt(t, nil)
+
+	certPEM, keyPEM := encryptedPEMPair(t, "s3cret")
+	_, err := loadClientCert(config.ClientAuth{Cert: certPEM, Key: keyPEM, Passphrase: "s3cret"})
+	assert.Nil(err)
+
+	_, err = loadClientCert(config.ClientAuth{Cert: certPEM, Key: keyPEM, Passphrase: "wrong"})
+	assert.NotNil(err)
+	assert.Contains(err.Error(), "incorrect passphrase for 'the client key'")
+
+	_, err = loadClientCert(config.ClientAuth{Cert: certPEM, Key: keyPEM})
+	assert.True(errors.Is(err, ErrPassphraseRequired))
+}
+
+func TestLoadClientCertEncryptedKeyFiles(t *testing.T) {
+	assert := assert.New(t)
+	withPrompt(t, func(name string) (string, error) {
+		return "s3cret", nil
+	})
+
+	certPEM, keyPEM := encryptedPEMPair(t, "s3cret")
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "client.pem"), []byte(certPEM), 0600)
+	os.WriteFile(filepath.Join(dir, "client.key"), []byte(keyPEM), 0600)
+
+	_, err := createTestClient(true, tenSeconds, config.ClientAuth{Cert: filepath.Join(dir, "client.pem"), Key: filepath.Join(dir, "client.key")})
+	assert.Nil(err)
+	assert.Equal("s3cret", promptedPassphrases[filepath.Join(dir, "client.key")])
+}
@@ -6,18 +6,22 @@ import (
 	"crypto/x509"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"os"
 	"strings"
-	"time"
 
 	"github.com/thoom/gulp/config"
 )
 
 var buildVersion string
 
+// tlsVerificationDisabled is set by DisableTLSVerification so that the transports built by the client skip verification too
+var tlsVerificationDisabled bool
+
 // DisableTLSVerification disables TLS verification
 func DisableTLSVerification() {
+	tlsVerificationDisabled = true
 	http.DefaultTransport.(*http.Transport).TLSClientConfig = &tls.Config{
 		InsecureSkipVerify: true,
 		VerifyPeerCertificate: func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
@@ -28,8 +32,8 @@ func DisableTLSVerification() {
 	}
 }
 
-// CreateRequest will create a request object
-func CreateRequest(method, url string, body []byte, headers map[string]string) (*http.Request, error) {
+// CreateRequest will create a request object. A Host header changes the host the request is sent with.
+func CreateRequest(method, url string, body []byte, headers http.Header) (*http.Request, error) {
 	var reader io.Reader
 
 	// Don't build the reader if using a GET/HEAD request
@@ -42,16 +46,53 @@ func CreateRequest(method, url string, body []byte, headers map[string]string) (
 		return nil, fmt.Errorf("could not build request: %s", err)
 	}
 
-	for k, v := range headers {
-		req.Header.Set(k, v)
+	if headers == nil {
+		return req, nil
+	}
+
+	req.Header = headers.Clone()
+	if host := req.Header.Get("Host"); host != "" {
+		req.Host = host
+		req.Header.Del("Host")
+	}
+
+	return req, nil
+}
+
+// CreateStreamRequest creates a request that streams the body with chunked transfer encoding.
+// Replayable bodies can be sent again for redirects and digest auth.
+func CreateStreamRequest(method, url string, stream *BodyStream, headers http.Header) (*http.Request, error) {
+	req, err := CreateRequest(method, url, nil, headers)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Body, err = stream.Open(); err != nil {
+		return nil, err
+	}
+
+	// An unknown length makes the transport use chunked transfer encoding
+	req.ContentLength = -1
+	if stream.Replayable() {
+		req.GetBody = stream.Open
 	}
+
 	return req, nil
 }
 
-// CreateClient will create a new http.Client with basic defaults
-func CreateClient(followRedirects bool, timeout int, clientCert config.ClientAuth) (*http.Client, error) {
+// ValidateClientAuth loads the client cert/key and CA the same way the client transports do, returning any error found
+func ValidateClientAuth(clientCert config.ClientAuth) error {
+	_, err := newTransport(config.Timeouts{}, clientCert, true)
+	return err
+}
+
+// newTransport builds a transport using the phase timeouts, client cert/CA and TLS verification setting
+func newTransport(timeouts config.Timeouts, clientCert config.ClientAuth, verifyTLS bool) (*http.Transport, error) {
 	tr := &http.Transport{
-		DisableCompression: false,
+		DisableCompression:    false,
+		DialContext:           (&net.Dialer{Timeout: timeouts.Connect}).DialContext,
+		TLSHandshakeTimeout:   timeouts.TLSHandshake,
+		ResponseHeaderTimeout: timeouts.ResponseHeader,
 	}
 
 	// Initialize TLS config
@@ -86,54 +127,28 @@ func CreateClient(followRedirects bool, timeout int, clientCert config.ClientAut
 
 	// Handle client certificate authentication
 	if clientCert.UseAuth() {
-		var cert tls.Certificate
-		var err error
-
-		certData := strings.TrimSpace(clientCert.Cert)
-		keyData := strings.TrimSpace(clientCert.Key)
-
-		// Check if both cert and key are direct PEM content
-		if strings.HasPrefix(certData, "-----BEGIN") && strings.HasPrefix(keyData, "-----BEGIN") {
-			// Both are direct PEM content
-			cert, err = tls.X509KeyPair([]byte(certData), []byte(keyData))
-		} else if !strings.HasPrefix(certData, "-----BEGIN") && !strings.HasPrefix(keyData, "-----BEGIN") {
-			// Both are file paths
-			cert, err = tls.LoadX509KeyPair(certData, keyData)
-		} else {
-			// Mixed format - one is inline, one is file path - this is an error
-			return nil, fmt.Errorf("client certificate and key must both be either file paths or inline PEM content, not mixed")
-		}
-
+		cert, err := loadClientCert(clientCert)
 		if err != nil {
-			return nil, fmt.Errorf("invalid client cert/key: %s", err)
+			return nil, err
 		}
 
 		tlsConfig.Certificates = []tls.Certificate{cert}
 	}
 
-	// Only set TLS config if we have either custom CA or client certs
-	if strings.TrimSpace(clientCert.CA) != "" || clientCert.UseAuth() {
-		tr.TLSClientConfig = tlsConfig
+	if !verifyTLS {
+		tlsConfig.InsecureSkipVerify = true
 	}
 
-	if !followRedirects {
-		return &http.Client{
-			Timeout:   time.Duration(timeout) * time.Second,
-			Transport: tr,
-			CheckRedirect: func(_ *http.Request, _ []*http.Request) error {
-				return http.ErrUseLastResponse
-			},
-		}, nil
+	// Only set TLS config if we have either custom CA or client certs, or verification is disabled
+	if strings.TrimSpace(clientCert.CA) != "" || clientCert.UseAuth() || !verifyTLS {
+		tr.TLSClientConfig = tlsConfig
 	}
 
-	return &http.Client{
-		Timeout:   time.Duration(timeout) * time.Second,
-		Transport: tr,
-	}, nil
+	return tr, nil
 }
 
 // Creates a ClientAuth object
-func BuildClientAuth(clientCert, clientCertKey, clientCA string, clientCertConfig config.ClientAuth) config.ClientAuth {
+func BuildClientAuth(clientCert, clientCertKey, clientCertPassphrase, clientCA string, clientCertConfig config.ClientAuth) config.ClientAuth {
 	clientAuth := clientCertConfig
 	if strings.TrimSpace(clientCert) != "" {
 		clientAuth.Cert = clientCert
@@ -143,6 +158,10 @@ func BuildClientAuth(clientCert, clientCertKey, clientCA string, clientCertConfi
 		clientAuth.Key = clientCertKey
 	}
 
+	if clientCertPassphrase != "" {
+		clientAuth.Passphrase = clientCertPassphrase
+	}
+
 	if strings.TrimSpace(clientCA) != "" {
 		clientAuth.CA = clientCA
 	}
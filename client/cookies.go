@@ -0,0 +1,269 @@
+package client
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// netscapeHeader starts the cookie files written by curl and browsers' cookies.txt exports
+const netscapeHeader = "# Netscape HTTP Cookie File"
+
+// httpOnlyPrefix marks HttpOnly cookies in the Netscape format (the way curl writes them)
+const httpOnlyPrefix = "#HttpOnly_"
+
+// storedCookie is a cookie as it's kept in the jar
+type storedCookie struct {
+	Domain string
+
+	// HostOnly cookies are only sent to the domain that set them, not its subdomains
+	HostOnly bool
+	Path     string
+	Secure   bool
+	HTTPOnly bool
+
+	// Expires is zero for session cookies
+	Expires time.Time
+	Name    string
+	Value   string
+}
+
+func (c *storedCookie) expired(now time.Time) bool {
+	return !c.Expires.IsZero() && !c.Expires.After(now)
+}
+
+// CookieJar is an http.CookieJar that can be saved to and loaded from a Netscape cookies.txt file.
+// Session cookies are saved too, so that a login carries over to the next run.
+type CookieJar struct {
+	mu      sync.Mutex
+	file    string
+	cookies []*storedCookie
+
+	now func() time.Time
+}
+
+// LoadCookieJar reads the cookies in the file. A missing file is an empty jar.
+func LoadCookieJar(file string) (*CookieJar, error) {
+	jar := &CookieJar{file: file, now: time.Now}
+
+	f, err := os.Open(file)
+	if os.IsNotExist(err) {
+		return jar, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not read cookies: %s", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimRight(scanner.Text(), "\r")
+		httpOnly := strings.HasPrefix(text, httpOnlyPrefix)
+		text = strings.TrimPrefix(text, httpOnlyPrefix)
+		if strings.TrimSpace(text) == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		fields := strings.Split(text, "\t")
+		if len(fields) != 7 {
+			return nil, fmt.Errorf("invalid cookie on line %d of '%s', expected 7 tab separated fields", line, file)
+		}
+
+		expires, err := strconv.ParseInt(fields[4], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cookie expiration '%s' on line %d of '%s'", fields[4], line, file)
+		}
+
+		cookie := &storedCookie{
+			Domain:   strings.ToLower(strings.TrimPrefix(fields[0], ".")),
+			HostOnly: !strings.EqualFold(fields[1], "TRUE"),
+			Path:     fields[2],
+			Secure:   strings.EqualFold(fields[3], "TRUE"),
+			HTTPOnly: httpOnly,
+			Name:     fields[5],
+			Value:    fields[6],
+		}
+		if expires > 0 {
+			cookie.Expires = time.Unix(expires, 0)
+		}
+
+		if !cookie.expired(jar.now()) {
+			jar.cookies = append(jar.cookies, cookie)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("could not read cookies: %s", err)
+	}
+
+	return jar, nil
+}
+
+// SetCookies implements http.CookieJar
+func (j *CookieJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	host := strings.ToLower(u.Hostname())
+	now := j.now()
+	for _, c := range cookies {
+		cookie := &storedCookie{Domain: host, HostOnly: true, Path: c.Path, Secure: c.Secure, HTTPOnly: c.HttpOnly, Name: c.Name, Value: c.Value}
+
+		if c.Domain != "" {
+			domain := strings.ToLower(strings.TrimPrefix(c.Domain, "."))
+
+			// Don't let a host set cookies for another site or a public suffix (ie. com or co.uk)
+			if !domainMatch(host, domain) || (domain != host && isPublicSuffix(domain)) {
+				continue
+			}
+			cookie.Domain, cookie.HostOnly = domain, net.ParseIP(host) != nil
+		}
+
+		if !strings.HasPrefix(cookie.Path, "/") {
+			cookie.Path = defaultCookiePath(u.Path)
+		}
+
+		switch {
+		case c.MaxAge < 0:
+			cookie.Expires = now
+		case c.MaxAge > 0:
+			cookie.Expires = now.Add(time.Duration(c.MaxAge) * time.Second)
+		case !c.Expires.IsZero():
+			cookie.Expires = c.Expires
+		}
+
+		j.store(cookie, now)
+	}
+}
+
+// isPublicSuffix checks whether anyone can register names directly under the domain, using the same list as browsers
+func isPublicSuffix(domain string) bool {
+	suffix, _ := publicsuffix.PublicSuffix(domain)
+	return suffix == domain
+}
+
+// store replaces the cookie with the same domain, path and name, dropping it if it has expired
+func (j *CookieJar) store(cookie *storedCookie, now time.Time) {
+	kept := j.cookies[:0]
+	for _, c := range j.cookies {
+		if c.Domain != cookie.Domain || c.Path != cookie.Path || c.Name != cookie.Name {
+			kept = append(kept, c)
+		}
+	}
+
+	j.cookies = kept
+	if !cookie.expired(now) {
+		j.cookies = append(j.cookies, cookie)
+	}
+}
+
+// Cookies implements http.CookieJar, returning the longest paths first
+func (j *CookieJar) Cookies(u *url.URL) []*http.Cookie {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	host := strings.ToLower(u.Hostname())
+	path := u.Path
+	if path == "" {
+		path = "/"
+	}
+
+	now := j.now()
+	var matched []*storedCookie
+	for _, c := range j.cookies {
+		if c.expired(now) || (c.Secure && u.Scheme != "https") || !pathMatch(path, c.Path) {
+			continue
+		}
+
+		if (c.HostOnly && host == c.Domain) || (!c.HostOnly && domainMatch(host, c.Domain)) {
+			matched = append(matched, c)
+		}
+	}
+
+	sort.SliceStable(matched, func(a, b int) bool {
+		return len(matched[a].Path) > len(matched[b].Path)
+	})
+
+	cookies := make([]*http.Cookie, len(matched))
+	for i, c := range matched {
+		cookies[i] = &http.Cookie{Name: c.Name, Value: c.Value}
+	}
+
+	return cookies
+}
+
+// Save writes the cookies that haven't expired to the jar's file, readable only by the user
+func (j *CookieJar) Save() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	var sb strings.Builder
+	sb.WriteString(netscapeHeader + "\n\n")
+
+	now := j.now()
+	for _, c := range j.cookies {
+		if c.expired(now) {
+			continue
+		}
+
+		domain, subdomains := c.Domain, "FALSE"
+		if !c.HostOnly {
+			domain, subdomains = "."+domain, "TRUE"
+		}
+		if c.HTTPOnly {
+			domain = httpOnlyPrefix + domain
+		}
+
+		var expires int64
+		if !c.Expires.IsZero() {
+			expires = c.Expires.Unix()
+		}
+
+		fmt.Fprintf(&sb, "%s\t%s\t%s\t%s\t%d\t%s\t%s\n", domain, subdomains, c.Path, strings.ToUpper(strconv.FormatBool(c.Secure)), expires, c.Name, c.Value)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(j.file), 0700); err != nil {
+		return fmt.Errorf("could not save cookies: %s", err)
+	}
+
+	if err := os.WriteFile(j.file, []byte(sb.String()), 0600); err != nil {
+		return fmt.Errorf("could not save cookies: %s", err)
+	}
+
+	return nil
+}
+
+// domainMatch checks whether the host is the domain or one of its subdomains
+func domainMatch(host, domain string) bool {
+	return host == domain || (strings.HasSuffix(host, "."+domain) && net.ParseIP(host) == nil)
+}
+
+// pathMatch checks whether the request path is within the cookie path (RFC 6265 section 5.1.4)
+func pathMatch(path, cookiePath string) bool {
+	if !strings.HasPrefix(path, cookiePath) {
+		return false
+	}
+
+	return len(path) == len(cookiePath) || strings.HasSuffix(cookiePath, "/") || path[len(cookiePath)] == '/'
+}
+
+// defaultCookiePath is the directory of the request path (RFC 6265 section 5.1.4)
+func defaultCookiePath(path string) string {
+	i := strings.LastIndex(path, "/")
+	if i <= 0 {
+		return "/"
+	}
+
+	return path[:i]
+}
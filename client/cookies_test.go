@@ -0,0 +1,174 @@
+package client
+
+import (
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func cookieNames(cookies []*http.Cookie) []string {
+	names := []string{}
+	for _, c := range cookies {
+		names = append(names, c.Name+"="+c.Value)
+	}
+
+	return names
+}
+
+func TestCookieJarScopes(t *testing.T) {
+	assert := assert.New(t)
+
+	jar, err := LoadCookieJar(filepath.Join(t.TempDir(), "cookies.txt"))
+	assert.Nil(err)
+
+	login, _ := url.Parse("https://auth.ex.io/v1/login")
+	jar.SetCookies(login, []*http.Cookie{
+		{Name: "host", Value: "1"},
+		{Name: "domain", Value: "2", Domain: ".ex.io", Path: "/"},
+		{Name: "secure", Value: "3", Path: "/", Secure: true},
+		{Name: "other", Value: "4", Domain: "other.io"},
+		{Name: "tld", Value: "5", Domain: "io"},
+	})
+
+	u, _ := url.Parse("https://auth.ex.io/v1/me")
+	assert.Equal([]string{"host=1", "domain=2", "secure=3"}, cookieNames(jar.Cookies(u)))
+
+	u, _ = url.Parse("http://auth.ex.io/other")
+	assert.Equal([]string{"domain=2"}, cookieNames(jar.Cookies(u)))
+
+	u, _ = url.Parse("https://api.ex.io/v1/me")
+	assert.Equal([]string{"domain=2"}, cookieNames(jar.Cookies(u)))
+
+	u, _ = url.Parse("https://other.io/")
+	assert.Empty(jar.Cookies(u))
+}
+
+func TestCookieJarPublicSuffix(t *testing.T) {
+	assert := assert.New(t)
+
+	jar, err := LoadCookieJar(filepath.Join(t.TempDir(), "cookies.txt"))
+	assert.Nil(err)
+
+	login, _ := url.Parse("https://foo.co.uk/login")
+	jar.SetCookies(login, []*http.Cookie{
+		{Name: "suffix", Value: "1", Domain: "co.uk"},
+		{Name: "site", Value: "2", Domain: "foo.co.uk"},
+	})
+
+	u, _ := url.Parse("https://bar.co.uk/")
+	assert.Empty(jar.Cookies(u))
+
+	u, _ = url.Parse("https://api.foo.co.uk/")
+	assert.Equal([]string{"site=2"}, cookieNames(jar.Cookies(u)))
+
+	// Hosts on a private suffix are separate sites too
+	pages, _ := url.Parse("https://me.github.io/")
+	jar.SetCookies(pages, []*http.Cookie{{Name: "pages", Value: "3", Domain: "github.io"}})
+
+	u, _ = url.Parse("https://other.github.io/")
+	assert.Empty(jar.Cookies(u))
+}
+
+func TestCookieJarExpiration(t *testing.T) {
+	assert := assert.New(t)
+
+	jar, _ := LoadCookieJar(filepath.Join(t.TempDir(), "cookies.txt"))
+	now := time.Unix(1700000000, 0)
+	jar.now = func() time.Time { return now }
+
+	u, _ := url.Parse("https://api.ex.io/")
+	jar.SetCookies(u, []*http.Cookie{
+		{Name: "max_age", Value: "1", MaxAge: 60},
+		{Name: "expires", Value: "2", Expires: now.Add(time.Hour)},
+		{Name: "expired", Value: "3", Expires: now.Add(-time.Hour)},
+	})
+	assert.Equal([]string{"max_age=1", "expires=2"}, cookieNames(jar.Cookies(u)))
+
+	// Replaced, then deleted
+	jar.SetCookies(u, []*http.Cookie{{Name: "expires", Value: "changed"}})
+	jar.SetCookies(u, []*http.Cookie{{Name: "max_age", MaxAge: -1}})
+	assert.Equal([]string{"expires=changed"}, cookieNames(jar.Cookies(u)))
+
+	now = now.Add(2 * time.Hour)
+	assert.Equal([]string{"expires=changed"}, cookieNames(jar.Cookies(u)))
+}
+
+func TestCookieJarSaveLoad(t *testing.T) {
+	assert := assert.New(t)
+
+	file := filepath.Join(t.TempDir(), "session", "cookies.txt")
+	jar, _ := LoadCookieJar(file)
+
+	u, _ := url.Parse("https://api.ex.io/v1/login")
+	jar.SetCookies(u, []*http.Cookie{
+		{Name: "sid", Value: "abc", HttpOnly: true, Secure: true},
+		{Name: "pref", Value: "dark", Domain: "ex.io", Path: "/", Expires: time.Unix(2000000000, 0)},
+	})
+	assert.Nil(jar.Save())
+
+	dat, _ := os.ReadFile(file)
+	assert.Equal("# Netscape HTTP Cookie File\n\n"+
+		"#HttpOnly_api.ex.io\tFALSE\t/v1\tTRUE\t0\tsid\tabc\n"+
+		".ex.io\tTRUE\t/\tFALSE\t2000000000\tpref\tdark\n", string(dat))
+
+	info, _ := os.Stat(file)
+	assert.Equal(os.FileMode(0600), info.Mode().Perm())
+
+	loaded, err := LoadCookieJar(file)
+	assert.Nil(err)
+	assert.Equal(jar.cookies, loaded.cookies)
+}
+
+func TestLoadCookieJarCurl(t *testing.T) {
+	assert := assert.New(t)
+
+	// A file written by curl -c
+	file := filepath.Join(t.TempDir(), "cookies.txt")
+	os.WriteFile(file, []byte(strings.Join([]string{
+		"# Netscape HTTP Cookie File",
+		"# https://curl.se/docs/http-cookies.html",
+		"# This file was generated by libcurl! Edit at your own risk.",
+		"",
+		"api.ex.io\tFALSE\t/\tFALSE\t0\tsid\tabc",
+		"#HttpOnly_.ex.io\tTRUE\t/\tTRUE\t1\told\tgone",
+	}, "\r\n")), 0600)
+
+	jar, err := LoadCookieJar(file)
+	assert.Nil(err)
+
+	u, _ := url.Parse("https://api.ex.io/anything")
+	assert.Equal([]string{"sid=abc"}, cookieNames(jar.Cookies(u)))
+}
+
+func TestLoadCookieJarInvalid(t *testing.T) {
+	assert := assert.New(t)
+
+	file := filepath.Join(t.TempDir(), "cookies.txt")
+	os.WriteFile(file, []byte("api.ex.io\tFALSE\t/\n"), 0600)
+	_, err := LoadCookieJar(file)
+	assert.EqualError(err, "invalid cookie on line 1 of '"+file+"', expected 7 tab separated fields")
+
+	os.WriteFile(file, []byte("api.ex.io\tFALSE\t/\tFALSE\tsoon\tsid\tabc\n"), 0600)
+	_, err = LoadCookieJar(file)
+	assert.EqualError(err, "invalid cookie expiration 'soon' on line 1 of '"+file+"'")
+}
+
+func TestPathMatch(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.True(pathMatch("/v1", "/v1"))
+	assert.True(pathMatch("/v1/me", "/v1"))
+	assert.True(pathMatch("/v1/me", "/"))
+	assert.False(pathMatch("/v10", "/v1"))
+	assert.False(pathMatch("/", "/v1"))
+
+	assert.Equal("/", defaultCookiePath(""))
+	assert.Equal("/", defaultCookiePath("/login"))
+	assert.Equal("/v1", defaultCookiePath("/v1/login"))
+}
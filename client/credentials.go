@@ -0,0 +1,146 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/thoom/gulp/config"
+)
+
+// CredentialRequest is written to the credential helper's stdin
+type CredentialRequest struct {
+	Host   string `json:"host"`
+	Method string `json:"method"`
+	Path   string `json:"path"`
+}
+
+// CredentialResponse is read from the credential helper's stdout.
+// The expiry is either an RFC 3339 expires_at or a number of seconds in expires_in.
+type CredentialResponse struct {
+	Headers   map[string]string `json:"headers"`
+	ExpiresAt time.Time         `json:"expires_at,omitempty"`
+	ExpiresIn int64             `json:"expires_in,omitempty"`
+}
+
+// cachedCredentials are the headers returned by the helper, kept until they expire
+type cachedCredentials struct {
+	headers map[string]string
+
+	// expiresAt is zero when the helper didn't set an expiry
+	expiresAt time.Time
+}
+
+// CredentialHelper runs an external command to get the headers to add to each request,
+// like the git and docker credential helpers. The results are cached in memory until they expire.
+type CredentialHelper struct {
+	command []string
+
+	mu    sync.Mutex
+	cache map[CredentialRequest]cachedCredentials
+
+	// run executes the command, it's replaced in tests
+	run func(command []string, input []byte) ([]byte, error)
+	now func() time.Time
+}
+
+// NewCredentialHelper splits the command into the executable and its arguments
+func NewCredentialHelper(command string) (*CredentialHelper, error) {
+	fields := strings.Fields(command)
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("credential_helper needs a command to run")
+	}
+
+	return &CredentialHelper{command: fields, cache: map[CredentialRequest]cachedCredentials{}, run: runCredentialHelper, now: time.Now}, nil
+}
+
+// runCredentialHelper runs the command with the input on stdin, letting it write prompts or errors to stderr
+func runCredentialHelper(command []string, input []byte) ([]byte, error) {
+	var stdout bytes.Buffer
+	cmd := exec.Command(command[0], command[1:]...)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = &stdout
+	cmd.Stderr = os.Stderr
+
+	if err := cmd.Run(); err != nil {
+		return nil, err
+	}
+
+	return stdout.Bytes(), nil
+}
+
+// Headers returns the helper's headers for the request, running it if nothing valid is cached.
+// The lock is held while the helper runs so that concurrent requests only run it once.
+func (h *CredentialHelper) Headers(credReq CredentialRequest) (map[string]string, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	now := h.now()
+	if cached, ok := h.cache[credReq]; ok && (cached.expiresAt.IsZero() || now.Add(tokenExpiryLeeway).Before(cached.expiresAt)) {
+		return cached.headers, nil
+	}
+
+	input, _ := json.Marshal(credReq)
+	out, err := h.run(h.command, input)
+	if err != nil {
+		return nil, fmt.Errorf("credential helper '%s' failed: %s", h.command[0], err)
+	}
+
+	var credResp CredentialResponse
+	if err := json.Unmarshal(out, &credResp); err != nil {
+		return nil, fmt.Errorf("invalid credential helper response from '%s': %s", h.command[0], err)
+	}
+
+	cached := cachedCredentials{headers: credResp.Headers, expiresAt: credResp.ExpiresAt}
+	if credResp.ExpiresIn > 0 {
+		cached.expiresAt = now.Add(time.Duration(credResp.ExpiresIn) * time.Second)
+	}
+	h.cache[credReq] = cached
+
+	return cached.headers, nil
+}
+
+// Secrets returns the values of the sensitive headers the helper returned, so that they can be masked.
+// Other headers, like a tenant name or an Accept type, aren't secrets.
+func (h *CredentialHelper) Secrets() []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var secrets []string
+	for _, cached := range h.cache {
+		for k, v := range cached.headers {
+			if config.IsSensitiveHeader(k) {
+				secrets = append(secrets, v)
+			}
+		}
+	}
+
+	return secrets
+}
+
+// Sign adds the helper's headers to the request. Headers that are already set, ie. with -H, aren't replaced.
+func (h *CredentialHelper) Sign(req *http.Request, _ []byte) error {
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+
+	headers, err := h.Headers(CredentialRequest{Host: req.URL.Host, Method: req.Method, Path: path})
+	if err != nil {
+		return err
+	}
+
+	for k, v := range headers {
+		if len(req.Header.Values(k)) == 0 {
+			req.Header.Set(k, v)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,156 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// stubHelper returns a helper that answers with the response and counts how often it was run
+func stubHelper(response string, runs *int) *CredentialHelper {
+	helper, _ := NewCredentialHelper("gulp-helper get")
+	helper.run = func(command []string, input []byte) ([]byte, error) {
+		*runs++
+		return []byte(response), nil
+	}
+	return helper
+}
+
+func TestNewCredentialHelperEmpty(t *testing.T) {
+	assert := assert.New(t)
+
+	_, err := NewCredentialHelper("  ")
+	assert.Equal("credential_helper needs a command to run", fmt.Sprintf("%s", err))
+}
+
+func TestCredentialHelperHeaders(t *testing.T) {
+	assert := assert.New(t)
+
+	var input CredentialRequest
+	var command []string
+	helper, _ := NewCredentialHelper("gulp-helper  --vault  prod")
+	helper.run = func(c []string, in []byte) ([]byte, error) {
+		command = c
+		json.Unmarshal(in, &input)
+		return []byte(`{"headers": {"Authorization": "Bearer abc123", "X-Tenant": "acme"}}`), nil
+	}
+
+	headers, err := helper.Headers(CredentialRequest{Host: "api.ex.io", Method: "GET", Path: "/users"})
+	assert.Nil(err)
+	assert.Equal(map[string]string{"Authorization": "Bearer abc123", "X-Tenant": "acme"}, headers)
+	assert.Equal([]string{"gulp-helper", "--vault", "prod"}, command)
+	assert.Equal(CredentialRequest{Host: "api.ex.io", Method: "GET", Path: "/users"}, input)
+
+	// Only the values of sensitive headers are secrets
+	assert.Equal([]string{"Bearer abc123"}, helper.Secrets())
+}
+
+func TestCredentialHelperCache(t *testing.T) {
+	assert := assert.New(t)
+
+	runs := 0
+	helper := stubHelper(`{"headers": {"X-Token": "abc"}}`, &runs)
+	credReq := CredentialRequest{Host: "api.ex.io", Method: "GET", Path: "/"}
+
+	// Without an expiry the headers are used for the whole run
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			helper.Headers(credReq)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(1, runs)
+
+	// Other requests run the helper again
+	helper.Headers(CredentialRequest{Host: "api.ex.io", Method: "POST", Path: "/"})
+	assert.Equal(2, runs)
+}
+
+func TestCredentialHelperExpiry(t *testing.T) {
+	assert := assert.New(t)
+
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	credReq := CredentialRequest{Host: "api.ex.io", Method: "GET", Path: "/"}
+
+	runs := 0
+	helper := stubHelper(`{"headers": {"X-Token": "abc"}, "expires_in": 120}`, &runs)
+	helper.now = func() time.Time { return now }
+	helper.Headers(credReq)
+	helper.now = func() time.Time { return now.Add(time.Minute) }
+	helper.Headers(credReq)
+	assert.Equal(1, runs)
+
+	// Credentials are refreshed a little before they expire
+	helper.now = func() time.Time { return now.Add(100 * time.Second) }
+	helper.Headers(credReq)
+	assert.Equal(2, runs)
+
+	runs = 0
+	helper = stubHelper(`{"headers": {"X-Token": "abc"}, "expires_at": "2024-01-01T12:00:10Z"}`, &runs)
+	helper.now = func() time.Time { return now }
+	helper.Headers(credReq)
+	helper.Headers(credReq)
+	assert.Equal(2, runs)
+}
+
+func TestCredentialHelperErrors(t *testing.T) {
+	assert := assert.New(t)
+
+	runs := 0
+	helper := stubHelper(`not json`, &runs)
+	_, err := helper.Headers(CredentialRequest{Host: "api.ex.io"})
+	assert.Contains(fmt.Sprintf("%s", err), "invalid credential helper response from 'gulp-helper': ")
+
+	helper, _ = NewCredentialHelper("/nonexistent/gulp-helper")
+	_, err = helper.Headers(CredentialRequest{Host: "api.ex.io"})
+	assert.Contains(fmt.Sprintf("%s", err), "credential helper '/nonexistent/gulp-helper' failed: ")
+}
+
+func TestCredentialHelperSign(t *testing.T) {
+	assert := assert.New(t)
+
+	runs := 0
+	helper := stubHelper(`{"headers": {"Authorization": "Bearer abc123", "X-Tenant": "acme"}}`, &runs)
+
+	req, _ := http.NewRequest("GET", "https://api.ex.io:8443", nil)
+	req.Header.Set("X-Tenant", "other")
+	assert.Nil(helper.Sign(req, nil))
+
+	// Headers that were already set win
+	assert.Equal("Bearer abc123", req.Header.Get("Authorization"))
+	assert.Equal("other", req.Header.Get("X-Tenant"))
+	assert.Contains(helper.cache, CredentialRequest{Host: "api.ex.io:8443", Method: "GET", Path: "/"})
+}
+
+func TestCredentialHelperCommand(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a shell script")
+	}
+	assert := assert.New(t)
+
+	dir := t.TempDir()
+	script := filepath.Join(dir, "helper.sh")
+	os.WriteFile(script, []byte("#!/bin/sh\ncat > \"$1\"\necho '{\"headers\": {\"X-Token\": \"abc\"}}'\n"), 0700)
+
+	input := filepath.Join(dir, "input.json")
+	helper, err := NewCredentialHelper(script + " " + input)
+	assert.Nil(err)
+
+	headers, err := helper.Headers(CredentialRequest{Host: "api.ex.io", Method: "GET", Path: "/"})
+	assert.Nil(err)
+	assert.Equal(map[string]string{"X-Token": "abc"}, headers)
+
+	dat, _ := os.ReadFile(input)
+	assert.Equal(`{"host":"api.ex.io","method":"GET","path":"/"}`, string(dat))
+}
@@ -0,0 +1,230 @@
+package client
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// DigestAuth answers RFC 7616 Digest challenges with the username and password
+type DigestAuth struct {
+	Username string
+	Password string
+
+	// Host is the only host (ie. api.ex.io:8443) whose challenges are answered, so credentials don't follow redirects
+	Host string
+
+	// OnChallenge is called with the challenged request and the 401 response before the request is replayed
+	OnChallenge func(req *http.Request, resp *http.Response)
+
+	cnonce func() string
+}
+
+// Client returns a copy of the client that replays challenged requests with a Digest Authorization header
+func (d *DigestAuth) Client(c *http.Client) *http.Client {
+	base := c.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	withDigest := *c
+	withDigest.Transport = &digestTransport{base: base, auth: d}
+	return &withDigest
+}
+
+type digestTransport struct {
+	base http.RoundTripper
+	auth *DigestAuth
+}
+
+// RoundTrip implements http.RoundTripper
+func (dt *digestTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := dt.base.RoundTrip(req)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized || !strings.EqualFold(req.URL.Host, dt.auth.Host) {
+		return resp, err
+	}
+
+	challenge := parseDigestChallenge(resp.Header.Values("WWW-Authenticate"))
+	if challenge == nil {
+		return resp, nil
+	}
+
+	// The body has to be sent again, which http.NewRequest allows for in-memory bodies
+	var body []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		if req.GetBody == nil {
+			return resp, nil
+		}
+
+		reader, err := req.GetBody()
+		if err != nil {
+			return resp, nil
+		}
+		body, _ = io.ReadAll(reader)
+		reader.Close()
+	}
+
+	authorization, err := dt.auth.authorize(challenge, req.Method, req.URL.RequestURI(), body)
+	if err != nil {
+		// Leave unsupported challenges to the caller
+		return resp, nil
+	}
+
+	if dt.auth.OnChallenge != nil {
+		dt.auth.OnChallenge(req, resp)
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+
+	replay := req.Clone(req.Context())
+	if req.GetBody != nil {
+		if replay.Body, err = req.GetBody(); err != nil {
+			return nil, err
+		}
+	}
+	replay.Header.Set("Authorization", authorization)
+
+	return dt.base.RoundTrip(replay)
+}
+
+// parseDigestChallenge returns the parameters of the first Digest challenge, preferring SHA-256 when several are offered
+func parseDigestChallenge(headers []string) map[string]string {
+	var found map[string]string
+	for _, header := range headers {
+		scheme, params, _ := strings.Cut(strings.TrimSpace(header), " ")
+		if !strings.EqualFold(scheme, "Digest") {
+			continue
+		}
+
+		challenge := parseAuthParams(params)
+		if found == nil || strings.HasPrefix(strings.ToUpper(challenge["algorithm"]), "SHA-256") {
+			found = challenge
+		}
+	}
+
+	return found
+}
+
+// parseAuthParams parses comma separated name=value pairs, where the values can be quoted strings with commas
+func parseAuthParams(value string) map[string]string {
+	params := map[string]string{}
+	for value != "" {
+		value = strings.TrimLeft(value, " ,")
+		name, rest, ok := strings.Cut(value, "=")
+		if !ok {
+			break
+		}
+		name = strings.ToLower(strings.TrimSpace(name))
+		rest = strings.TrimLeft(rest, " ")
+
+		var param strings.Builder
+		if strings.HasPrefix(rest, `"`) {
+			i := 1
+			for ; i < len(rest) && rest[i] != '"'; i++ {
+				if rest[i] == '\\' && i+1 < len(rest) {
+					i++
+				}
+				param.WriteByte(rest[i])
+			}
+			value = rest[min(i+1, len(rest)):]
+		} else {
+			token, remaining, _ := strings.Cut(rest, ",")
+			param.WriteString(strings.TrimSpace(token))
+			value = remaining
+		}
+
+		params[name] = param.String()
+	}
+
+	return params
+}
+
+// authorize computes the Authorization header that answers the challenge
+func (d *DigestAuth) authorize(challenge map[string]string, method, uri string, body []byte) (string, error) {
+	algorithm := challenge["algorithm"]
+	if algorithm == "" {
+		algorithm = "MD5"
+	}
+
+	var newHash func() hash.Hash
+	switch strings.TrimSuffix(strings.ToUpper(algorithm), "-SESS") {
+	case "MD5":
+		newHash = md5.New
+	case "SHA-256":
+		newHash = sha256.New
+	default:
+		return "", fmt.Errorf("unsupported digest algorithm '%s'", algorithm)
+	}
+
+	h := func(value string) string {
+		sum := newHash()
+		sum.Write([]byte(value))
+		return hex.EncodeToString(sum.Sum(nil))
+	}
+
+	// Prefer auth since auth-int needs the whole body, but use auth-int when it's the only option
+	qop := ""
+	for _, offered := range strings.Split(challenge["qop"], ",") {
+		switch offered = strings.TrimSpace(offered); {
+		case offered == "auth":
+			qop = offered
+		case offered == "auth-int" && qop == "":
+			qop = offered
+		}
+	}
+
+	if challenge["qop"] != "" && qop == "" {
+		return "", fmt.Errorf("unsupported digest qop '%s'", challenge["qop"])
+	}
+
+	nonce, cnonce, nc := challenge["nonce"], d.newCnonce(), "00000001"
+	ha1 := h(d.Username + ":" + challenge["realm"] + ":" + d.Password)
+	if strings.HasSuffix(strings.ToUpper(algorithm), "-SESS") {
+		ha1 = h(ha1 + ":" + nonce + ":" + cnonce)
+	}
+
+	ha2 := h(method + ":" + uri)
+	if qop == "auth-int" {
+		ha2 = h(method + ":" + uri + ":" + h(string(body)))
+	}
+
+	var response string
+	if qop == "" {
+		response = h(ha1 + ":" + nonce + ":" + ha2)
+	} else {
+		response = h(strings.Join([]string{ha1, nonce, nc, cnonce, qop, ha2}, ":"))
+	}
+
+	params := []string{
+		fmt.Sprintf("username=%q", d.Username),
+		fmt.Sprintf("realm=%q", challenge["realm"]),
+		fmt.Sprintf("uri=%q", uri),
+		fmt.Sprintf("algorithm=%s", algorithm),
+		fmt.Sprintf("nonce=%q", nonce),
+	}
+	if qop != "" {
+		params = append(params, "nc="+nc, fmt.Sprintf("cnonce=%q", cnonce), "qop="+qop)
+	}
+	params = append(params, fmt.Sprintf("response=%q", response))
+	if opaque, ok := challenge["opaque"]; ok {
+		params = append(params, fmt.Sprintf("opaque=%q", opaque))
+	}
+
+	return "Digest " + strings.Join(params, ", "), nil
+}
+
+func (d *DigestAuth) newCnonce() string {
+	if d.cnonce != nil {
+		return d.cnonce()
+	}
+
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
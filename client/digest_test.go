@@ -0,0 +1,177 @@
+package client
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/thoom/gulp/config"
+)
+
+// rfcDigest uses the credentials from the RFC 7616 examples
+func rfcDigest() *DigestAuth {
+	return &DigestAuth{
+		Username: "Mufasa",
+		Password: "Circle of Life",
+		cnonce:   func() string { return "f2/wE4q74E6zIJEtWaHKaf5wv/H5QzzpXusqGemxURZJ" },
+	}
+}
+
+func rfcChallenge(algorithm string) map[string]string {
+	return parseAuthParams(`realm="http-auth@example.org", qop="auth, auth-int", algorithm=` + algorithm +
+		`, nonce="7ypf/xlj9XXwfDPEoM4URrv/xwf94BcCAzFZH4GiTo0v", opaque="FQhe/qaU925kfnzjCev0ciny7QMkPqMAFRtzCUYo5tdS"`)
+}
+
+func TestDigestAuthorizeMD5(t *testing.T) {
+	assert := assert.New(t)
+
+	authorization, err := rfcDigest().authorize(rfcChallenge("MD5"), "GET", "/dir/index.html", nil)
+	assert.Nil(err)
+	assert.Equal(`Digest username="Mufasa", realm="http-auth@example.org", uri="/dir/index.html", algorithm=MD5, `+
+		`nonce="7ypf/xlj9XXwfDPEoM4URrv/xwf94BcCAzFZH4GiTo0v", nc=00000001, cnonce="f2/wE4q74E6zIJEtWaHKaf5wv/H5QzzpXusqGemxURZJ", qop=auth, `+
+		`response="8ca523f5e9506fed4657c9700eebdbec", opaque="FQhe/qaU925kfnzjCev0ciny7QMkPqMAFRtzCUYo5tdS"`, authorization)
+}
+
+func TestDigestAuthorizeSHA256(t *testing.T) {
+	assert := assert.New(t)
+
+	authorization, err := rfcDigest().authorize(rfcChallenge("SHA-256"), "GET", "/dir/index.html", nil)
+	assert.Nil(err)
+	assert.Contains(authorization, `response="753927fa0e85d155564e2e272a28d1802ca10daf4496794697cf8db5856cb6c1"`)
+}
+
+func TestDigestAuthorizeAuthInt(t *testing.T) {
+	assert := assert.New(t)
+
+	h := func(value string) string {
+		sum := md5.Sum([]byte(value))
+		return hex.EncodeToString(sum[:])
+	}
+
+	body := []byte(`{"name":"gulp"}`)
+	challenge := map[string]string{"realm": "api", "nonce": "abc", "qop": "auth-int"}
+	authorization, err := rfcDigest().authorize(challenge, "POST", "/users", body)
+	assert.Nil(err)
+
+	ha1 := h("Mufasa:api:Circle of Life")
+	ha2 := h("POST:/users:" + h(string(body)))
+	expected := h(ha1 + ":abc:00000001:f2/wE4q74E6zIJEtWaHKaf5wv/H5QzzpXusqGemxURZJ:auth-int:" + ha2)
+	assert.Contains(authorization, "qop=auth-int, ")
+	assert.Contains(authorization, `response="`+expected+`"`)
+	assert.NotContains(authorization, "opaque")
+}
+
+func TestDigestAuthorizeNoQop(t *testing.T) {
+	assert := assert.New(t)
+
+	h := func(value string) string {
+		sum := md5.Sum([]byte(value))
+		return hex.EncodeToString(sum[:])
+	}
+
+	authorization, err := rfcDigest().authorize(map[string]string{"realm": "api", "nonce": "abc"}, "GET", "/", nil)
+	assert.Nil(err)
+	assert.NotContains(authorization, "qop")
+	assert.Contains(authorization, `response="`+h(h("Mufasa:api:Circle of Life")+":abc:"+h("GET:/"))+`"`)
+}
+
+func TestDigestAuthorizeUnsupported(t *testing.T) {
+	assert := assert.New(t)
+
+	_, err := rfcDigest().authorize(map[string]string{"algorithm": "SHA-512-256"}, "GET", "/", nil)
+	assert.Equal("unsupported digest algorithm 'SHA-512-256'", fmt.Sprintf("%s", err))
+
+	_, err = rfcDigest().authorize(map[string]string{"qop": "auth-conf"}, "GET", "/", nil)
+	assert.Equal("unsupported digest qop 'auth-conf'", fmt.Sprintf("%s", err))
+}
+
+func TestParseDigestChallenge(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Nil(parseDigestChallenge([]string{`Basic realm="api"`}))
+	assert.Equal(map[string]string{"realm": "a, b", "nonce": `x"y`, "algorithm": "SHA-256", "stale": "FALSE"}, parseDigestChallenge([]string{
+		`Digest realm="api", nonce="abc", algorithm=MD5`,
+		`Digest realm="a, b", nonce="x\"y", algorithm=SHA-256, stale=FALSE`,
+		`Basic realm="api"`,
+	}))
+}
+
+// digestServer requires Digest auth and echoes the body once the request is authorized
+func digestServer(t *testing.T, qop string) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization := r.Header.Get("Authorization")
+		if !strings.HasPrefix(authorization, "Digest ") {
+			w.Header().Set("WWW-Authenticate", `Digest realm="api", nonce="abc", qop="`+qop+`", algorithm=MD5`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		params := parseAuthParams(strings.TrimPrefix(authorization, "Digest "))
+		body, _ := io.ReadAll(r.Body)
+		d := &DigestAuth{Username: "admin", Password: "s3cret", cnonce: func() string { return params["cnonce"] }}
+		expected, _ := d.authorize(map[string]string{"realm": "api", "nonce": "abc", "qop": qop}, r.Method, r.URL.RequestURI(), body)
+		if authorization != expected {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		w.Write(body)
+	}))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func TestDigestAuthClient(t *testing.T) {
+	assert := assert.New(t)
+
+	server := digestServer(t, "auth-int")
+	var challenged *http.Response
+	digest := &DigestAuth{Username: "admin", Password: "s3cret", Host: URLHost(server.URL), OnChallenge: func(req *http.Request, resp *http.Response) {
+		challenged = resp
+	}}
+
+	c, err := createTestClient(true, tenSeconds, config.New.ClientAuth)
+	assert.Nil(err)
+
+	req, _ := CreateRequest("POST", server.URL+"/users?page=2", []byte(`{"name":"gulp"}`), nil)
+	resp, err := digest.Client(c).Do(req)
+	assert.Nil(err)
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	assert.Equal(http.StatusOK, resp.StatusCode)
+	assert.Equal(`{"name":"gulp"}`, string(body))
+	assert.Equal(http.StatusUnauthorized, challenged.StatusCode)
+	assert.Contains(resp.Request.Header.Get("Authorization"), `username="admin"`)
+}
+
+func TestDigestAuthClientWrongPassword(t *testing.T) {
+	assert := assert.New(t)
+
+	server := digestServer(t, "auth")
+	digest := &DigestAuth{Username: "admin", Password: "wrong", Host: URLHost(server.URL)}
+
+	resp, err := digest.Client(&http.Client{}).Get(server.URL)
+	assert.Nil(err)
+	assert.Equal(http.StatusUnauthorized, resp.StatusCode)
+}
+
+func TestDigestAuthClientOtherHost(t *testing.T) {
+	assert := assert.New(t)
+
+	server := digestServer(t, "auth")
+	called := false
+	digest := &DigestAuth{Username: "admin", Password: "s3cret", Host: "api.ex.io", OnChallenge: func(*http.Request, *http.Response) { called = true }}
+
+	resp, err := digest.Client(&http.Client{}).Get(server.URL)
+	assert.Nil(err)
+	assert.Equal(http.StatusUnauthorized, resp.StatusCode)
+	assert.False(called)
+}
@@ -0,0 +1,190 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"net/textproto"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"github.com/thoom/gulp/config"
+)
+
+// FormContentType is the Content-Type of URL encoded form bodies
+const FormContentType = "application/x-www-form-urlencoded"
+
+// quoteEscaper escapes the names in a Content-Disposition header the same way the multipart package does
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+// FormField is a form field with either a value or a file to upload
+type FormField struct {
+	Name  string
+	Value string
+
+	// File is the path of the file to upload. The Content-Type is guessed from its extension if it isn't set,
+	// and the filename defaults to the file's name.
+	File        string
+	ContentType string
+	Filename    string
+}
+
+// ParseFormField parses a -F field: name=value, or name=@path with optional ;type= and ;filename= attributes
+func ParseFormField(field string) (FormField, error) {
+	name, value, ok := strings.Cut(field, "=")
+	if name = strings.TrimSpace(name); !ok || name == "" {
+		return FormField{}, fmt.Errorf("invalid form field '%s', expected name=value or name=@file", field)
+	}
+
+	return MultipartField(name, value)
+}
+
+// MultipartField parses the value of a multipart field, where @path uploads the file
+func MultipartField(name, value string) (FormField, error) {
+	path, isFile := strings.CutPrefix(value, "@")
+	if !isFile {
+		return FormField{Name: name, Value: value}, nil
+	}
+
+	attributes := strings.Split(path, ";")
+	field := FormField{Name: name, File: strings.TrimSpace(attributes[0])}
+	if field.File == "" {
+		return FormField{}, fmt.Errorf("invalid form field '%s=%s', expected a file after @", name, value)
+	}
+
+	for _, attribute := range attributes[1:] {
+		key, v, _ := strings.Cut(attribute, "=")
+		switch strings.ToLower(strings.TrimSpace(key)) {
+		case "type":
+			field.ContentType = strings.TrimSpace(v)
+		case "filename":
+			field.Filename = strings.TrimSpace(v)
+		default:
+			return FormField{}, fmt.Errorf("invalid form field '%s=%s', unknown attribute '%s', expected type or filename", name, value, strings.TrimSpace(key))
+		}
+	}
+
+	return field, nil
+}
+
+// EncodeForm builds an application/x-www-form-urlencoded body from the field values
+func EncodeForm(fields []FormField) []byte {
+	values := url.Values{}
+	for _, field := range fields {
+		values.Add(field.Name, field.Value)
+	}
+
+	return []byte(values.Encode())
+}
+
+// EncodeMultipart builds a multipart/form-data body, returning it along with its Content-Type (which includes the boundary)
+func EncodeMultipart(fields []FormField) ([]byte, string, error) {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	for _, field := range fields {
+		if field.File == "" {
+			if err := writer.WriteField(field.Name, field.Value); err != nil {
+				return nil, "", fmt.Errorf("could not build the multipart body: %s", err)
+			}
+			continue
+		}
+
+		dat, err := os.ReadFile(field.File)
+		if err != nil {
+			return nil, "", fmt.Errorf("could not read the file for form field '%s': %s", field.Name, err)
+		}
+
+		filename := field.Filename
+		if filename == "" {
+			filename = filepath.Base(field.File)
+		}
+
+		contentType := field.ContentType
+		if contentType == "" {
+			contentType = mime.TypeByExtension(filepath.Ext(field.File))
+		}
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+
+		header := textproto.MIMEHeader{}
+		header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`, quoteEscaper.Replace(field.Name), quoteEscaper.Replace(filename)))
+		header.Set("Content-Type", contentType)
+
+		part, err := writer.CreatePart(header)
+		if err != nil {
+			return nil, "", fmt.Errorf("could not build the multipart body: %s", err)
+		}
+		part.Write(dat)
+	}
+
+	if err := writer.Close(); err != nil {
+		return nil, "", fmt.Errorf("could not build the multipart body: %s", err)
+	}
+
+	return body.Bytes(), writer.FormDataContentType(), nil
+}
+
+// DecodeFormPayload checks whether a YAML/JSON payload only has a form or multipart key, ie.
+//
+//	multipart:
+//	  name: gulp
+//	  avatar: "@me.png;type=image/png"
+//
+// and returns the key along with its fields, sorted by name. Relative paths to the uploaded files are
+// resolved against dir, the directory of the payload file. Other payloads return an empty key.
+func DecodeFormPayload(body []byte, dir string) (string, []FormField, error) {
+	dat, err := yaml.YAMLToJSON(body)
+	if err != nil {
+		return "", nil, nil
+	}
+
+	var payload map[string]json.RawMessage
+	if json.Unmarshal(dat, &payload) != nil || len(payload) != 1 {
+		return "", nil, nil
+	}
+
+	kind := "form"
+	raw, ok := payload[kind]
+	if !ok {
+		kind = "multipart"
+		if raw, ok = payload[kind]; !ok {
+			return "", nil, nil
+		}
+	}
+
+	var values map[string]config.StringList
+	if err := json.Unmarshal(raw, &values); err != nil {
+		return "", nil, fmt.Errorf("invalid %s payload, expected a map of field names to a value or a list of values", kind)
+	}
+
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var fields []FormField
+	for _, name := range names {
+		for _, value := range values[name] {
+			field := FormField{Name: name, Value: value}
+			if kind == "multipart" {
+				if field, err = MultipartField(name, value); err != nil {
+					return "", nil, err
+				}
+				if field.File != "" && !filepath.IsAbs(field.File) {
+					field.File = filepath.Join(dir, field.File)
+				}
+			}
+			fields = append(fields, field)
+		}
+	}
+
+	return kind, fields, nil
+}
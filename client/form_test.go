@@ -0,0 +1,142 @@
+package client
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseFormField(t *testing.T) {
+	assert := assert.New(t)
+
+	field, err := ParseFormField("name=gulp=cli")
+	assert.Nil(err)
+	assert.Equal(FormField{Name: "name", Value: "gulp=cli"}, field)
+
+	field, err = ParseFormField("avatar=@me.png;type=image/png;filename=avatar.png")
+	assert.Nil(err)
+	assert.Equal(FormField{Name: "avatar", File: "me.png", ContentType: "image/png", Filename: "avatar.png"}, field)
+
+	field, err = ParseFormField("empty=")
+	assert.Nil(err)
+	assert.Equal(FormField{Name: "empty"}, field)
+}
+
+func TestParseFormFieldInvalid(t *testing.T) {
+	assert := assert.New(t)
+
+	_, err := ParseFormField("avatar")
+	assert.Equal("invalid form field 'avatar', expected name=value or name=@file", fmt.Sprintf("%s", err))
+
+	_, err = ParseFormField("=gulp")
+	assert.NotNil(err)
+
+	_, err = ParseFormField("avatar=@")
+	assert.Equal("invalid form field 'avatar=@', expected a file after @", fmt.Sprintf("%s", err))
+
+	_, err = ParseFormField("avatar=@me.png;size=2")
+	assert.Equal("invalid form field 'avatar=@me.png;size=2', unknown attribute 'size', expected type or filename", fmt.Sprintf("%s", err))
+}
+
+func TestEncodeForm(t *testing.T) {
+	assert := assert.New(t)
+
+	body := EncodeForm([]FormField{{Name: "name", Value: "gulp cli"}, {Name: "tag", Value: "a&b"}, {Name: "tag", Value: "c"}})
+	assert.Equal("name=gulp+cli&tag=a%26b&tag=c", string(body))
+}
+
+func TestEncodeMultipart(t *testing.T) {
+	assert := assert.New(t)
+
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "me.png"), []byte("png"), 0644)
+	os.WriteFile(filepath.Join(dir, "data"), []byte("raw"), 0644)
+
+	body, contentType, err := EncodeMultipart([]FormField{
+		{Name: "name", Value: "gulp"},
+		{Name: "avatar", File: filepath.Join(dir, "me.png")},
+		{Name: "data", File: filepath.Join(dir, "data"), Filename: "data.bin"},
+		{Name: "doc", File: filepath.Join(dir, "me.png"), ContentType: "image/x-test"},
+	})
+	assert.Nil(err)
+
+	mediaType, params, _ := mime.ParseMediaType(contentType)
+	assert.Equal("multipart/form-data", mediaType)
+
+	reader := multipart.NewReader(bytes.NewReader(body), params["boundary"])
+	expected := []struct{ name, filename, contentType, value string }{
+		{"name", "", "", "gulp"},
+		{"avatar", "me.png", "image/png", "png"},
+		{"data", "data.bin", "application/octet-stream", "raw"},
+		{"doc", "me.png", "image/x-test", "png"},
+	}
+	for _, e := range expected {
+		part, err := reader.NextPart()
+		assert.Nil(err)
+		assert.Equal(e.name, part.FormName())
+		assert.Equal(e.filename, part.FileName())
+		assert.Equal(e.contentType, part.Header.Get("Content-Type"))
+
+		value, _ := io.ReadAll(part)
+		assert.Equal(e.value, string(value))
+	}
+
+	_, err = reader.NextPart()
+	assert.Equal(io.EOF, err)
+}
+
+func TestEncodeMultipartMissingFile(t *testing.T) {
+	assert := assert.New(t)
+
+	_, _, err := EncodeMultipart([]FormField{{Name: "avatar", File: filepath.Join(t.TempDir(), "missing.png")}})
+	assert.Contains(fmt.Sprintf("%s", err), "could not read the file for form field 'avatar'")
+}
+
+func TestDecodeFormPayload(t *testing.T) {
+	assert := assert.New(t)
+
+	kind, fields, err := DecodeFormPayload([]byte("form:\n  tag: [a, b]\n  name: gulp\n  count: 2\n"), "payloads")
+	assert.Nil(err)
+	assert.Equal("form", kind)
+	assert.Equal([]FormField{{Name: "count", Value: "2"}, {Name: "name", Value: "gulp"}, {Name: "tag", Value: "a"}, {Name: "tag", Value: "b"}}, fields)
+
+	// The files are relative to the payload file
+	doc, _ := filepath.Abs("doc.pdf")
+	payload := fmt.Sprintf(`{"multipart": {"name": "gulp", "avatar": "@me.png;type=image/png", "doc": %q}}`, "@"+doc)
+	kind, fields, err = DecodeFormPayload([]byte(payload), "payloads")
+	assert.Nil(err)
+	assert.Equal("multipart", kind)
+	assert.Equal([]FormField{
+		{Name: "avatar", File: filepath.Join("payloads", "me.png"), ContentType: "image/png"},
+		{Name: "doc", File: doc},
+		{Name: "name", Value: "gulp"},
+	}, fields)
+}
+
+func TestDecodeFormPayloadOther(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, body := range []string{"name: gulp", "form:\n  name: gulp\nother: true", "[1, 2]", "plain text", ""} {
+		kind, fields, err := DecodeFormPayload([]byte(body), ".")
+		assert.Nil(err)
+		assert.Empty(kind)
+		assert.Empty(fields)
+	}
+}
+
+func TestDecodeFormPayloadInvalid(t *testing.T) {
+	assert := assert.New(t)
+
+	_, _, err := DecodeFormPayload([]byte("form:\n  user:\n    name: gulp\n"), ".")
+	assert.Equal("invalid form payload, expected a map of field names to a value or a list of values", fmt.Sprintf("%s", err))
+
+	_, _, err = DecodeFormPayload([]byte("multipart:\n  avatar: \"@\"\n"), ".")
+	assert.Equal("invalid form field 'avatar=@', expected a file after @", fmt.Sprintf("%s", err))
+}
@@ -0,0 +1,113 @@
+package client
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/thoom/gulp/config"
+)
+
+// HostOptions contains the settings that can differ between the hosts a request visits
+type HostOptions struct {
+	ClientAuth config.ClientAuth
+	Timeouts   config.Timeouts
+	VerifyTLS  bool
+
+	// Headers are the configured and -H headers for the host
+	Headers http.Header
+
+	// HostHeaders are the names of the headers set by the host's blocks. They're set on redirected requests
+	// that land on the host, and a redirect that leaves it sends the next host's values for them instead.
+	HostHeaders []string
+}
+
+// Go drops these on redirects to other domains, so configured values aren't restored for them
+var strippedOnRedirect = map[string]bool{"Authorization": true, "Www-Authenticate": true, "Cookie": true, "Cookie2": true}
+
+// HostResolver returns the options to use for requests sent to a host (ie. api.ex.io:8443)
+type HostResolver func(host string) HostOptions
+
+// hostTransport sends each request through a transport built for its host,
+// so redirected hops use the client auth, TLS and timeout settings of the host they land on
+type hostTransport struct {
+	mu         sync.Mutex
+	resolve    HostResolver
+	transports map[string]*http.Transport
+}
+
+func (ht *hostTransport) transport(host string) (*http.Transport, error) {
+	host = strings.ToLower(host)
+
+	ht.mu.Lock()
+	defer ht.mu.Unlock()
+
+	if tr, ok := ht.transports[host]; ok {
+		return tr, nil
+	}
+
+	opts := ht.resolve(host)
+	tr, err := newTransport(opts.Timeouts, opts.ClientAuth, opts.VerifyTLS && !tlsVerificationDisabled)
+	if err != nil {
+		return nil, err
+	}
+
+	ht.transports[host] = tr
+	return tr, nil
+}
+
+// RoundTrip implements http.RoundTripper
+func (ht *hostTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	tr, err := ht.transport(req.URL.Host)
+	if err != nil {
+		return nil, err
+	}
+
+	return tr.RoundTrip(req)
+}
+
+// CreateHostClient creates a client that resolves its settings per host, starting with the host of the request.
+// The overall timeout comes from the starting host since it covers every hop.
+func CreateHostClient(followRedirects bool, host string, resolve HostResolver) (*http.Client, error) {
+	ht := &hostTransport{resolve: resolve, transports: map[string]*http.Transport{}}
+
+	// Build the transport for the starting host up front so that any cert problems are reported right away
+	if _, err := ht.transport(host); err != nil {
+		return nil, err
+	}
+
+	return &http.Client{
+		Timeout:   resolve(host).Timeouts.Overall,
+		Transport: ht,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if !followRedirects {
+				return http.ErrUseLastResponse
+			}
+
+			// Match the default policy of the http.Client
+			if len(via) >= 10 {
+				return errors.New("stopped after 10 redirects")
+			}
+
+			// Go copies the headers of the first request on every hop, and only strips the credentials it knows.
+			// The headers from the first host's blocks go back to the values configured for the next host.
+			from, to := resolve(via[0].URL.Host), resolve(req.URL.Host)
+			for _, k := range from.HostHeaders {
+				req.Header.Del(k)
+				if v, ok := to.Headers[k]; ok && !strippedOnRedirect[k] {
+					req.Header[k] = append([]string(nil), v...)
+				}
+			}
+
+			for _, k := range to.HostHeaders {
+				req.Header.Del(k)
+				if v, ok := to.Headers[k]; ok {
+					req.Header[k] = append([]string(nil), v...)
+				}
+			}
+
+			return nil
+		},
+	}, nil
+}
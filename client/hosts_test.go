@@ -0,0 +1,161 @@
+package client
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/thoom/gulp/config"
+)
+
+func TestCreateHostClientRedirectHeaders(t *testing.T) {
+	assert := assert.New(t)
+
+	var received http.Header
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header
+	}))
+	defer target.Close()
+
+	start := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, target.URL, http.StatusFound)
+	}))
+	defer start.Close()
+
+	targetHost := strings.TrimPrefix(target.URL, "http://")
+	var resolved []string
+	client, err := CreateHostClient(true, URLHost(start.URL), func(host string) HostOptions {
+		resolved = append(resolved, host)
+		opts := HostOptions{Timeouts: tenSeconds, VerifyTLS: true}
+		if host == targetHost {
+			opts.Headers = http.Header{"X-Target": {"abc123"}}
+			opts.HostHeaders = []string{"X-Target"}
+		}
+		return opts
+	})
+	assert.Nil(err)
+	assert.Equal(10*time.Second, client.Timeout)
+
+	resp, err := client.Get(start.URL)
+	assert.Nil(err)
+	resp.Body.Close()
+
+	assert.Equal(http.StatusOK, resp.StatusCode)
+	assert.Equal("abc123", received.Get("X-Target"))
+	assert.Contains(resolved, targetHost)
+}
+
+func TestCreateHostClientRedirectRemovesHeaders(t *testing.T) {
+	assert := assert.New(t)
+
+	var final, middle http.Header
+	finalServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		final = r.Header
+	}))
+	defer finalServer.Close()
+
+	middleServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		middle = r.Header
+		http.Redirect(w, r, finalServer.URL, http.StatusFound)
+	}))
+	defer middleServer.Close()
+
+	internal := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, middleServer.URL, http.StatusFound)
+	}))
+	defer internal.Close()
+
+	internalHost, middleHost := URLHost(internal.URL), URLHost(middleServer.URL)
+	client, err := CreateHostClient(true, internalHost, func(host string) HostOptions {
+		opts := HostOptions{Timeouts: tenSeconds, VerifyTLS: true, Headers: http.Header{"X-Team": {"core"}}}
+		switch host {
+		case internalHost:
+			opts.Headers = http.Header{"X-Team": {"internal"}, "X-Internal-Token": {"s3cret"}}
+			opts.HostHeaders = []string{"X-Team", "X-Internal-Token"}
+		case middleHost:
+			opts.Headers.Set("X-Middle", "middle")
+			opts.HostHeaders = []string{"X-Middle"}
+		}
+		return opts
+	})
+	assert.Nil(err)
+
+	// The first request carries the host block's headers, like main does
+	req, _ := http.NewRequest("GET", internal.URL, nil)
+	req.Header.Set("X-Internal-Token", "s3cret")
+	req.Header.Set("X-Team", "internal")
+	req.Header.Set("X-Trace", "abc")
+
+	resp, err := client.Do(req)
+	assert.Nil(err)
+	resp.Body.Close()
+	assert.Equal(http.StatusOK, resp.StatusCode)
+
+	// The base value that the host block replaced is restored
+	assert.NotContains(middle, "X-Internal-Token")
+	assert.Equal("core", middle.Get("X-Team"))
+	assert.Equal("middle", middle.Get("X-Middle"))
+	assert.Equal("abc", middle.Get("X-Trace"))
+
+	// Go copies the first request's headers on every hop, not the previous hop's
+	assert.NotContains(final, "X-Internal-Token")
+	assert.NotContains(final, "X-Middle")
+	assert.Equal("core", final.Get("X-Team"))
+	assert.Equal("abc", final.Get("X-Trace"))
+}
+
+func TestCreateHostClientNoRedirect(t *testing.T) {
+	assert := assert.New(t)
+
+	start := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "http://other.ex.io/", http.StatusFound)
+	}))
+	defer start.Close()
+
+	client, err := CreateHostClient(false, URLHost(start.URL), func(host string) HostOptions {
+		return HostOptions{Timeouts: tenSeconds, VerifyTLS: true}
+	})
+	assert.Nil(err)
+
+	resp, err := client.Get(start.URL)
+	assert.Nil(err)
+	resp.Body.Close()
+	assert.Equal(http.StatusFound, resp.StatusCode)
+}
+
+func TestCreateHostClientTransportPerHost(t *testing.T) {
+	assert := assert.New(t)
+
+	// TestDisableTLS turns verification off for the whole package
+	disabled := tlsVerificationDisabled
+	tlsVerificationDisabled = false
+	defer func() { tlsVerificationDisabled = disabled }()
+
+	ht := &hostTransport{transports: map[string]*http.Transport{}, resolve: func(host string) HostOptions {
+		return HostOptions{VerifyTLS: host != "insecure.ex.io", Timeouts: config.Timeouts{TLSHandshake: time.Second}}
+	}}
+
+	secure, err := ht.transport("api.ex.io")
+	assert.Nil(err)
+	assert.Nil(secure.TLSClientConfig)
+
+	insecure, err := ht.transport("INSECURE.ex.io")
+	assert.Nil(err)
+	assert.True(insecure.TLSClientConfig.InsecureSkipVerify)
+	assert.Equal(time.Second, insecure.TLSHandshakeTimeout)
+
+	again, _ := ht.transport("insecure.ex.io")
+	assert.Same(insecure, again)
+}
+
+func TestCreateHostClientCertError(t *testing.T) {
+	assert := assert.New(t)
+
+	_, err := CreateHostClient(true, "api.ex.io", func(host string) HostOptions {
+		return HostOptions{ClientAuth: config.ClientAuth{CA: "/nonexistent/ca.pem"}}
+	})
+	assert.NotNil(err)
+}
@@ -0,0 +1,216 @@
+package client
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/thoom/gulp/config"
+)
+
+// defaultJWTClaims are used when the claims template isn't set
+const defaultJWTClaims = `{"iat": {{iat}}, "exp": {{exp}}, "jti": "{{jti}}"}`
+
+// JWTSigner signs a new JWT for each request
+type JWTSigner struct {
+	// headerName is the request header the token is sent in
+	headerName string
+
+	// joseHeader is the encoded JWT header
+	joseHeader string
+	claims     *template.Template
+	expiresIn  time.Duration
+	sign       func(signingInput []byte) ([]byte, error)
+
+	now func() time.Time
+}
+
+// NewJWTSigner loads the key and parses the claims template, then signs a token to check that they work
+func NewJWTSigner(jwt config.JWT) (*JWTSigner, error) {
+	signer := &JWTSigner{headerName: jwt.HeaderName(), expiresIn: 5 * time.Minute, now: time.Now}
+
+	var err error
+	switch jwt.Algorithm {
+	case "HS256":
+		if jwt.Secret == "" {
+			return nil, fmt.Errorf("jwt needs a secret to sign with HS256")
+		}
+
+		secret := []byte(jwt.Secret)
+		signer.sign = func(signingInput []byte) ([]byte, error) {
+			return hmacSHA256(secret, string(signingInput)), nil
+		}
+	case "RS256", "ES256":
+		if signer.sign, err = jwtKeySigner(jwt.Algorithm, jwt.Key); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("invalid jwt algorithm '%s', expected 'RS256', 'ES256' or 'HS256'", jwt.Algorithm)
+	}
+
+	if jwt.ExpiresIn != "" {
+		if signer.expiresIn, err = config.ParseTimeout(jwt.ExpiresIn); err != nil {
+			return nil, fmt.Errorf("invalid jwt expires_in: %s", err)
+		}
+	}
+
+	claims := jwt.Claims
+	if claims == "" {
+		claims = defaultJWTClaims
+	}
+
+	// The helpers are replaced for each token (see Token)
+	signer.claims, err = template.New("claims").Funcs(jwtFuncs(time.Time{}, 0)).Parse(claims)
+	if err != nil {
+		return nil, fmt.Errorf("invalid jwt claims: %s", err)
+	}
+
+	header, _ := json.Marshal(struct {
+		Algorithm string `json:"alg"`
+		Type      string `json:"typ"`
+		KeyID     string `json:"kid,omitempty"`
+	}{jwt.Algorithm, "JWT", jwt.KeyID})
+	signer.joseHeader = base64.RawURLEncoding.EncodeToString(header)
+
+	if _, err := signer.Token(); err != nil {
+		return nil, err
+	}
+
+	return signer, nil
+}
+
+// jwtFuncs are the helpers available in the claims template
+func jwtFuncs(now time.Time, expiresIn time.Duration) template.FuncMap {
+	return template.FuncMap{
+		"iat": func() int64 {
+			return now.Unix()
+		},
+		"exp": func() int64 {
+			return now.Add(expiresIn).Unix()
+		},
+		"jti": func() string {
+			b := make([]byte, 16)
+			rand.Read(b)
+			return hex.EncodeToString(b)
+		},
+	}
+}
+
+// jwtKeySigner loads the PEM private key (a file or inline content) used with RS256 or ES256
+func jwtKeySigner(algorithm, key string) (func([]byte) ([]byte, error), error) {
+	if key == "" {
+		return nil, fmt.Errorf("jwt needs a private key to sign with %s", algorithm)
+	}
+
+	data := []byte(key)
+	if !strings.HasPrefix(key, "-----BEGIN") {
+		var err error
+		if data, err = os.ReadFile(key); err != nil {
+			return nil, fmt.Errorf("could not read jwt key: %s", err)
+		}
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("invalid jwt key: no PEM data found")
+	}
+
+	var parsed interface{}
+	var err error
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		parsed, err = x509.ParseECPrivateKey(block.Bytes)
+	default:
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid jwt key: %s", err)
+	}
+
+	switch k := parsed.(type) {
+	case *rsa.PrivateKey:
+		if algorithm == "RS256" {
+			return func(signingInput []byte) ([]byte, error) {
+				digest := sha256.Sum256(signingInput)
+				return rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, digest[:])
+			}, nil
+		}
+	case *ecdsa.PrivateKey:
+		if algorithm == "ES256" && k.Curve == elliptic.P256() {
+			return func(signingInput []byte) ([]byte, error) {
+				digest := sha256.Sum256(signingInput)
+				r, s, err := ecdsa.Sign(rand.Reader, k, digest[:])
+				if err != nil {
+					return nil, err
+				}
+
+				// JWS uses the fixed size r || s form rather than ASN.1
+				signature := make([]byte, 64)
+				r.FillBytes(signature[:32])
+				s.FillBytes(signature[32:])
+				return signature, nil
+			}, nil
+		}
+	}
+
+	return nil, fmt.Errorf("invalid jwt key: %s needs an RSA key for RS256 or a P-256 EC key for ES256", algorithm)
+}
+
+// Token signs a new token with the current time and a random jti
+func (s *JWTSigner) Token() (string, error) {
+	claimsTemplate, err := s.claims.Clone()
+	if err != nil {
+		return "", err
+	}
+
+	var claims bytes.Buffer
+	if err := claimsTemplate.Funcs(jwtFuncs(s.now(), s.expiresIn)).Execute(&claims, nil); err != nil {
+		return "", fmt.Errorf("could not build the jwt claims: %s", err)
+	}
+
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, claims.Bytes()); err != nil || !bytes.HasPrefix(compact.Bytes(), []byte("{")) {
+		return "", fmt.Errorf("invalid jwt claims, expected a JSON object: %s", strconv.Quote(claims.String()))
+	}
+
+	signingInput := s.joseHeader + "." + base64.RawURLEncoding.EncodeToString(compact.Bytes())
+	signature, err := s.sign([]byte(signingInput))
+	if err != nil {
+		return "", fmt.Errorf("could not sign the jwt: %s", err)
+	}
+
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// Sign sets the header to a new token, as a Bearer token in the Authorization header
+func (s *JWTSigner) Sign(req *http.Request, _ []byte) error {
+	token, err := s.Token()
+	if err != nil {
+		return err
+	}
+
+	if strings.EqualFold(s.headerName, "Authorization") {
+		token = "Bearer " + token
+	}
+
+	req.Header.Set(s.headerName, token)
+	return nil
+}
@@ -0,0 +1,146 @@
+package client
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// NetrcCredentials are the login and password of a .netrc entry
+type NetrcCredentials struct {
+	Login    string
+	Password string
+}
+
+// DefaultNetrcFile returns $NETRC, falling back to ~/.netrc (~/_netrc on Windows) like curl
+func DefaultNetrcFile() string {
+	if file := os.Getenv("NETRC"); file != "" {
+		return file
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+
+	if runtime.GOOS == "windows" {
+		return filepath.Join(home, "_netrc")
+	}
+
+	return filepath.Join(home, ".netrc")
+}
+
+// FindNetrc returns the credentials of the first machine entry for the host (the port is ignored),
+// or of the default entry. It returns nil if neither has a login, or if an optional file doesn't exist.
+func FindNetrc(file, host string, optional bool) (*NetrcCredentials, error) {
+	dat, err := os.ReadFile(file)
+	if err != nil {
+		if optional && os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("could not read netrc file: %s", err)
+	}
+
+	if hostname, _, err := net.SplitHostPort(host); err == nil {
+		host = hostname
+	}
+
+	tokens, err := netrcTokens(string(dat))
+	if err != nil {
+		return nil, fmt.Errorf("could not parse netrc file '%s': %s", file, err)
+	}
+
+	var found *NetrcCredentials
+	var current *NetrcCredentials
+	for i := 0; i < len(tokens); i++ {
+		switch tokens[i] {
+		case "machine", "default":
+			// The first match wins and default only applies to the machines that aren't listed
+			if found != nil {
+				return found, nil
+			}
+
+			current = nil
+			if tokens[i] == "default" {
+				current = &NetrcCredentials{}
+			} else if i+1 < len(tokens) {
+				i++
+				if strings.EqualFold(tokens[i], host) {
+					current = &NetrcCredentials{}
+				}
+			}
+			found = current
+		case "login", "password", "account":
+			if i+1 >= len(tokens) {
+				return nil, fmt.Errorf("could not parse netrc file '%s': missing value for '%s'", file, tokens[i])
+			}
+
+			i++
+			if current == nil {
+				continue
+			}
+
+			if tokens[i-1] == "login" {
+				current.Login = tokens[i]
+			} else if tokens[i-1] == "password" {
+				current.Password = tokens[i]
+			}
+		}
+	}
+
+	if found == nil || found.Login == "" {
+		return nil, nil
+	}
+
+	return found, nil
+}
+
+// netrcTokens splits the file into whitespace separated tokens, skipping comments and macro definitions.
+// Values can be double quoted to include spaces, with backslash escapes.
+func netrcTokens(data string) ([]string, error) {
+	var tokens []string
+	lines := strings.Split(strings.ReplaceAll(data, "\r\n", "\n"), "\n")
+	for n := 0; n < len(lines); n++ {
+		line := strings.TrimSpace(lines[n])
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		for ; line != ""; line = strings.TrimSpace(line) {
+			var token string
+			if strings.HasPrefix(line, `"`) {
+				var sb strings.Builder
+				i := 1
+				for ; i < len(line) && line[i] != '"'; i++ {
+					if line[i] == '\\' && i+1 < len(line) {
+						i++
+					}
+					sb.WriteByte(line[i])
+				}
+				if i >= len(line) {
+					return nil, fmt.Errorf("unterminated quote on line %d", n+1)
+				}
+				token, line = sb.String(), line[i+1:]
+			} else if i := strings.IndexAny(line, " \t"); i >= 0 {
+				token, line = line[:i], line[i:]
+			} else {
+				token, line = line, ""
+			}
+
+			tokens = append(tokens, token)
+		}
+
+		// A macro runs until the next empty line
+		if len(tokens) >= 2 && tokens[len(tokens)-2] == "macdef" {
+			tokens = tokens[:len(tokens)-2]
+			for n+1 < len(lines) && strings.TrimSpace(lines[n+1]) != "" {
+				n++
+			}
+		}
+	}
+
+	return tokens, nil
+}
@@ -0,0 +1,99 @@
+package client
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testNetrc = `# Work machines
+machine api.ex.io login alice password "s3cret pass"
+machine other.ex.io
+  login bob
+  password hunter2
+  account ops
+
+machine macro.ex.io login carol password abc
+macdef init
+machine api.ex.io login mallory password nope
+
+default login anonymous password guest@ex.io
+`
+
+func writeNetrc(t *testing.T, content string) string {
+	file := filepath.Join(t.TempDir(), ".netrc")
+	os.WriteFile(file, []byte(content), 0600)
+	return file
+}
+
+func TestFindNetrc(t *testing.T) {
+	assert := assert.New(t)
+	file := writeNetrc(t, testNetrc)
+
+	creds, err := FindNetrc(file, "API.ex.io:8443", false)
+	assert.Nil(err)
+	assert.Equal(&NetrcCredentials{Login: "alice", Password: "s3cret pass"}, creds)
+
+	creds, err = FindNetrc(file, "other.ex.io", false)
+	assert.Nil(err)
+	assert.Equal(&NetrcCredentials{Login: "bob", Password: "hunter2"}, creds)
+
+	creds, err = FindNetrc(file, "macro.ex.io", false)
+	assert.Nil(err)
+	assert.Equal(&NetrcCredentials{Login: "carol", Password: "abc"}, creds)
+
+	creds, err = FindNetrc(file, "unknown.ex.io", false)
+	assert.Nil(err)
+	assert.Equal(&NetrcCredentials{Login: "anonymous", Password: "guest@ex.io"}, creds)
+}
+
+func TestFindNetrcNoMatch(t *testing.T) {
+	assert := assert.New(t)
+	file := writeNetrc(t, "machine api.ex.io login alice password s3cret\nmachine nologin.ex.io password abc\n")
+
+	creds, err := FindNetrc(file, "other.ex.io", false)
+	assert.Nil(err)
+	assert.Nil(creds)
+
+	creds, err = FindNetrc(file, "nologin.ex.io", false)
+	assert.Nil(err)
+	assert.Nil(creds)
+}
+
+func TestFindNetrcMissing(t *testing.T) {
+	assert := assert.New(t)
+	file := filepath.Join(t.TempDir(), ".netrc")
+
+	creds, err := FindNetrc(file, "api.ex.io", true)
+	assert.Nil(err)
+	assert.Nil(creds)
+
+	_, err = FindNetrc(file, "api.ex.io", false)
+	assert.NotNil(err)
+	assert.Contains(err.Error(), "could not read netrc file")
+}
+
+func TestFindNetrcInvalid(t *testing.T) {
+	assert := assert.New(t)
+
+	file := writeNetrc(t, "machine api.ex.io login")
+	_, err := FindNetrc(file, "api.ex.io", false)
+	assert.EqualError(err, "could not parse netrc file '"+file+"': missing value for 'login'")
+
+	file = writeNetrc(t, "machine api.ex.io login alice password \"abc\n")
+	_, err = FindNetrc(file, "api.ex.io", false)
+	assert.EqualError(err, "could not parse netrc file '"+file+"': unterminated quote on line 1")
+}
+
+func TestDefaultNetrcFile(t *testing.T) {
+	assert := assert.New(t)
+
+	t.Setenv("NETRC", "/etc/gulp/netrc")
+	assert.Equal("/etc/gulp/netrc", DefaultNetrcFile())
+
+	t.Setenv("NETRC", "")
+	t.Setenv("HOME", "/home/gulp")
+	assert.Equal(filepath.Join("/home/gulp", ".netrc"), DefaultNetrcFile())
+}
@@ -0,0 +1,229 @@
+package client
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/thoom/gulp/config"
+)
+
+// tokenExpiryLeeway treats tokens as expired a little early so they don't expire in flight
+const tokenExpiryLeeway = 30 * time.Second
+
+// OAuth2Token is an access token returned by the token endpoint
+type OAuth2Token struct {
+	AccessToken  string    `json:"access_token"`
+	RefreshToken string    `json:"refresh_token,omitempty"`
+	ExpiresAt    time.Time `json:"expires_at,omitempty"`
+}
+
+// valid checks whether the token can still be used. Tokens without an expiry are used until they're rejected.
+func (t *OAuth2Token) valid(now time.Time) bool {
+	return t != nil && t.AccessToken != "" && (t.ExpiresAt.IsZero() || now.Add(tokenExpiryLeeway).Before(t.ExpiresAt))
+}
+
+// OAuth2Source fetches access tokens and caches them in memory and on disk until they expire
+type OAuth2Source struct {
+	mu        sync.Mutex
+	config    config.OAuth2
+	client    *http.Client
+	cacheFile string
+	token     *OAuth2Token
+	now       func() time.Time
+}
+
+// NewOAuth2Source creates a token source. Tokens are cached in the cacheDir, unless it's empty.
+func NewOAuth2Source(oauth2 config.OAuth2, httpClient *http.Client, cacheDir string) (*OAuth2Source, error) {
+	if oauth2.TokenURL == "" {
+		return nil, fmt.Errorf("oauth2 needs a token_url")
+	}
+
+	if oauth2.Grant() == config.GrantRefreshToken && oauth2.RefreshToken == "" {
+		return nil, fmt.Errorf("oauth2 needs a refresh_token to use the refresh_token grant")
+	}
+
+	source := &OAuth2Source{config: oauth2, client: httpClient, now: time.Now}
+	if cacheDir != "" {
+		source.cacheFile = filepath.Join(cacheDir, "oauth2", oauth2CacheKey(oauth2)+".json")
+	}
+
+	return source, nil
+}
+
+// oauth2CacheKey identifies the token by everything that changes what the token endpoint returns
+func oauth2CacheKey(oauth2 config.OAuth2) string {
+	sum := sha256.Sum256([]byte(strings.Join([]string{
+		oauth2.TokenURL, oauth2.ClientID, oauth2.ClientSecret, strings.Join(oauth2.Scopes, " "), oauth2.Audience, oauth2.Grant(), oauth2.RefreshToken,
+	}, "\n")))
+
+	return hex.EncodeToString(sum[:])
+}
+
+// Token returns a valid access token, fetching a new one if the cached token expired
+func (s *OAuth2Source) Token() (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.token == nil {
+		s.token = s.readCache()
+	}
+
+	if s.token.valid(s.now()) {
+		return s.token.AccessToken, nil
+	}
+
+	return s.fetch()
+}
+
+// Refresh replaces a token that the server rejected. If another request already replaced it, the new token is returned.
+func (s *OAuth2Source) Refresh(rejected string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.token.valid(s.now()) && s.token.AccessToken != rejected {
+		return s.token.AccessToken, nil
+	}
+
+	return s.fetch()
+}
+
+// fetch gets a new token, using the refresh token from the last response if there is one
+func (s *OAuth2Source) fetch() (string, error) {
+	refreshToken := s.config.RefreshToken
+	if s.token != nil && s.token.RefreshToken != "" {
+		refreshToken = s.token.RefreshToken
+
+		// Fall back to the configured grant if the refresh token was revoked or expired
+		if token, err := s.request(config.GrantRefreshToken, refreshToken); err == nil {
+			s.setToken(token, refreshToken)
+			return token.AccessToken, nil
+		}
+		refreshToken = s.config.RefreshToken
+	}
+
+	token, err := s.request(s.config.Grant(), refreshToken)
+	if err != nil {
+		return "", err
+	}
+
+	s.setToken(token, refreshToken)
+	return token.AccessToken, nil
+}
+
+// setToken keeps the new token, along with the refresh token used to get it if the server didn't rotate it
+func (s *OAuth2Source) setToken(token *OAuth2Token, refreshToken string) {
+	if token.RefreshToken == "" {
+		token.RefreshToken = refreshToken
+	}
+
+	s.token = token
+	s.writeCache()
+}
+
+// request calls the token endpoint. The client credentials are sent with basic auth.
+func (s *OAuth2Source) request(grant, refreshToken string) (*OAuth2Token, error) {
+	form := url.Values{"grant_type": {grant}}
+	if grant == config.GrantRefreshToken {
+		form.Set("refresh_token", refreshToken)
+	}
+
+	if len(s.config.Scopes) > 0 {
+		form.Set("scope", strings.Join(s.config.Scopes, " "))
+	}
+
+	if s.config.Audience != "" {
+		form.Set("audience", s.config.Audience)
+	}
+
+	req, err := http.NewRequest("POST", s.config.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("could not build token request: %s", err)
+	}
+
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", CreateUserAgent())
+	req.SetBasicAuth(url.QueryEscape(s.config.ClientID), url.QueryEscape(s.config.ClientSecret))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("could not get an oauth2 token: %s", err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+
+	var tokenResp struct {
+		AccessToken      string      `json:"access_token"`
+		RefreshToken     string      `json:"refresh_token"`
+		ExpiresIn        json.Number `json:"expires_in"`
+		Error            string      `json:"error"`
+		ErrorDescription string      `json:"error_description"`
+	}
+	jsonErr := json.Unmarshal(body, &tokenResp)
+
+	if resp.StatusCode >= 400 || tokenResp.Error != "" {
+		if tokenResp.Error != "" {
+			return nil, fmt.Errorf("could not get an oauth2 token: %s %s", tokenResp.Error, tokenResp.ErrorDescription)
+		}
+
+		return nil, fmt.Errorf("could not get an oauth2 token: %s", resp.Status)
+	}
+
+	if jsonErr != nil || tokenResp.AccessToken == "" {
+		return nil, fmt.Errorf("could not get an oauth2 token: the response didn't contain an access_token")
+	}
+
+	token := &OAuth2Token{AccessToken: tokenResp.AccessToken, RefreshToken: tokenResp.RefreshToken}
+	if seconds, err := tokenResp.ExpiresIn.Int64(); err == nil && seconds > 0 {
+		token.ExpiresAt = s.now().Add(time.Duration(seconds) * time.Second)
+	}
+
+	return token, nil
+}
+
+// readCache returns the cached token, or nil if there isn't a usable one
+func (s *OAuth2Source) readCache() *OAuth2Token {
+	if s.cacheFile == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(s.cacheFile)
+	if err != nil {
+		return nil
+	}
+
+	token := &OAuth2Token{}
+	if err := json.Unmarshal(data, token); err != nil {
+		return nil
+	}
+
+	return token
+}
+
+// writeCache saves the token so that the next run can use it. Only the current user can read it.
+// A token that can't be cached is still used, it's just fetched again next time.
+func (s *OAuth2Source) writeCache() {
+	if s.cacheFile == "" {
+		return
+	}
+
+	data, err := json.Marshal(s.token)
+	if err != nil {
+		return
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.cacheFile), 0700); err == nil {
+		os.WriteFile(s.cacheFile, data, 0600)
+	}
+}
@@ -0,0 +1,192 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/thoom/gulp/config"
+)
+
+// tokenServer issues numbered tokens and records the last form it received
+func tokenServer(t *testing.T, expiresIn int, form *map[string]string) (*httptest.Server, *int32) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, pass, _ := r.BasicAuth()
+		if user != "gulp" || pass != "s3cret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"error":"invalid_client","error_description":"bad credentials"}`)
+			return
+		}
+
+		r.ParseForm()
+		if form != nil {
+			*form = map[string]string{}
+			for k := range r.PostForm {
+				(*form)[k] = r.PostForm.Get(k)
+			}
+		}
+
+		n := atomic.AddInt32(&calls, 1)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token":  fmt.Sprintf("token-%d", n),
+			"refresh_token": fmt.Sprintf("refresh-%d", n),
+			"expires_in":    expiresIn,
+		})
+	}))
+	t.Cleanup(server.Close)
+
+	return server, &calls
+}
+
+func TestNewOAuth2SourceErrors(t *testing.T) {
+	assert := assert.New(t)
+
+	_, err := NewOAuth2Source(config.OAuth2{}, http.DefaultClient, "")
+	assert.Equal("oauth2 needs a token_url", fmt.Sprintf("%s", err))
+
+	_, err = NewOAuth2Source(config.OAuth2{TokenURL: "https://auth.ex.io", GrantType: "refresh_token"}, http.DefaultClient, "")
+	assert.Equal("oauth2 needs a refresh_token to use the refresh_token grant", fmt.Sprintf("%s", err))
+}
+
+func TestOAuth2SourceClientCredentials(t *testing.T) {
+	assert := assert.New(t)
+
+	var form map[string]string
+	server, calls := tokenServer(t, 3600, &form)
+	source, err := NewOAuth2Source(config.OAuth2{
+		TokenURL:     server.URL,
+		ClientID:     "gulp",
+		ClientSecret: "s3cret",
+		Scopes:       config.StringList{"read", "write"},
+		Audience:     "https://api.ex.io",
+	}, server.Client(), "")
+	assert.Nil(err)
+
+	token, err := source.Token()
+	assert.Nil(err)
+	assert.Equal("token-1", token)
+	assert.Equal(map[string]string{"grant_type": "client_credentials", "scope": "read write", "audience": "https://api.ex.io"}, form)
+
+	// The token is reused until it expires
+	token, _ = source.Token()
+	assert.Equal("token-1", token)
+	assert.Equal(int32(1), *calls)
+}
+
+func TestOAuth2SourceExpired(t *testing.T) {
+	assert := assert.New(t)
+
+	var form map[string]string
+	server, _ := tokenServer(t, 60, &form)
+	source, _ := NewOAuth2Source(config.OAuth2{TokenURL: server.URL, ClientID: "gulp", ClientSecret: "s3cret"}, server.Client(), "")
+
+	now := time.Now()
+	source.now = func() time.Time { return now }
+	token, _ := source.Token()
+	assert.Equal("token-1", token)
+
+	// Tokens are refreshed a little before they expire, using the refresh token from the last response
+	now = now.Add(45 * time.Second)
+	token, err := source.Token()
+	assert.Nil(err)
+	assert.Equal("token-2", token)
+	assert.Equal(map[string]string{"grant_type": "refresh_token", "refresh_token": "refresh-1"}, form)
+}
+
+func TestOAuth2SourceRefreshTokenGrant(t *testing.T) {
+	assert := assert.New(t)
+
+	var form map[string]string
+	server, _ := tokenServer(t, 0, &form)
+	source, _ := NewOAuth2Source(config.OAuth2{
+		TokenURL:     server.URL,
+		ClientID:     "gulp",
+		ClientSecret: "s3cret",
+		GrantType:    "refresh_token",
+		RefreshToken: "configured",
+	}, server.Client(), "")
+
+	token, err := source.Token()
+	assert.Nil(err)
+	assert.Equal("token-1", token)
+	assert.Equal(map[string]string{"grant_type": "refresh_token", "refresh_token": "configured"}, form)
+	assert.True(source.token.ExpiresAt.IsZero())
+}
+
+func TestOAuth2SourceRefresh(t *testing.T) {
+	assert := assert.New(t)
+
+	server, calls := tokenServer(t, 3600, nil)
+	source, _ := NewOAuth2Source(config.OAuth2{TokenURL: server.URL, ClientID: "gulp", ClientSecret: "s3cret"}, server.Client(), "")
+
+	token, _ := source.Token()
+	assert.Equal("token-1", token)
+
+	token, err := source.Refresh("token-1")
+	assert.Nil(err)
+	assert.Equal("token-2", token)
+
+	// Another request already replaced the rejected token
+	token, _ = source.Refresh("token-1")
+	assert.Equal("token-2", token)
+	assert.Equal(int32(2), *calls)
+}
+
+func TestOAuth2SourceCache(t *testing.T) {
+	assert := assert.New(t)
+
+	cacheDir := t.TempDir()
+	server, calls := tokenServer(t, 3600, nil)
+	oauth2 := config.OAuth2{TokenURL: server.URL, ClientID: "gulp", ClientSecret: "s3cret"}
+
+	source, _ := NewOAuth2Source(oauth2, server.Client(), cacheDir)
+	token, _ := source.Token()
+	assert.Equal("token-1", token)
+
+	stat, err := os.Stat(filepath.Join(cacheDir, "oauth2", oauth2CacheKey(oauth2)+".json"))
+	assert.Nil(err)
+	assert.Equal(os.FileMode(0600), stat.Mode().Perm())
+
+	// A new run reads the cached token
+	source, _ = NewOAuth2Source(oauth2, server.Client(), cacheDir)
+	token, _ = source.Token()
+	assert.Equal("token-1", token)
+	assert.Equal(int32(1), *calls)
+
+	// Different settings use a different cache file
+	oauth2.Scopes = config.StringList{"admin"}
+	source, _ = NewOAuth2Source(oauth2, server.Client(), cacheDir)
+	token, _ = source.Token()
+	assert.Equal("token-2", token)
+}
+
+func TestOAuth2SourceErrorResponse(t *testing.T) {
+	assert := assert.New(t)
+
+	server, _ := tokenServer(t, 3600, nil)
+	source, _ := NewOAuth2Source(config.OAuth2{TokenURL: server.URL, ClientID: "gulp", ClientSecret: "wrong"}, server.Client(), "")
+
+	_, err := source.Token()
+	assert.Equal("could not get an oauth2 token: invalid_client bad credentials", fmt.Sprintf("%s", err))
+}
+
+func TestOAuth2SourceMissingToken(t *testing.T) {
+	assert := assert.New(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"token_type":"bearer"}`)
+	}))
+	defer server.Close()
+
+	source, _ := NewOAuth2Source(config.OAuth2{TokenURL: server.URL}, server.Client(), "")
+	_, err := source.Token()
+	assert.Equal("could not get an oauth2 token: the response didn't contain an access_token", fmt.Sprintf("%s", err))
+}
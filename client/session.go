@@ -0,0 +1,98 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Session keeps the cookies and the -H headers of a named session between runs.
+// Cookies are scoped to their domains by the jar, headers to the host they were sent to.
+type Session struct {
+	Jar *CookieJar
+
+	dir     string
+	headers map[string]http.Header
+}
+
+// OpenSession loads the session kept in the directory, starting a new one if it doesn't exist yet
+func OpenSession(dir string) (*Session, error) {
+	jar, err := LoadCookieJar(filepath.Join(dir, "cookies.txt"))
+	if err != nil {
+		return nil, err
+	}
+
+	session := &Session{Jar: jar, dir: dir, headers: map[string]http.Header{}}
+	dat, err := os.ReadFile(filepath.Join(dir, "headers.json"))
+	if os.IsNotExist(err) {
+		return session, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not read the session headers: %s", err)
+	}
+
+	if err := json.Unmarshal(dat, &session.headers); err != nil {
+		return nil, fmt.Errorf("could not parse the session headers in '%s': %s", filepath.Join(dir, "headers.json"), err)
+	}
+
+	return session, nil
+}
+
+// Headers returns the headers remembered for the host (ie. api.ex.io:8443)
+func (s *Session) Headers(host string) http.Header {
+	headers := s.headers[strings.ToLower(host)].Clone()
+	if headers == nil {
+		headers = make(http.Header)
+	}
+
+	return headers
+}
+
+// Remember keeps the -H headers for the next requests to the host, and forgets the ones removed with "Name:".
+// Cookies are left to the jar, and the headers that only describe this request's body or conditions
+// (ie. Content-Type, If-Match) aren't kept.
+func (s *Session) Remember(host string, reqHeaders []string) {
+	host = strings.ToLower(host)
+	replaced := map[string]bool{}
+	for _, header := range reqHeaders {
+		name, value, remove, err := ParseHeader(header)
+		if err != nil || name == "Cookie" || strings.HasPrefix(name, "Content-") || strings.HasPrefix(name, "If-") {
+			continue
+		}
+
+		if s.headers[host] == nil {
+			s.headers[host] = http.Header{}
+		}
+
+		// Like BuildHeaders, the first header with a name replaces the remembered values
+		if remove || !replaced[name] {
+			s.headers[host].Del(name)
+			replaced[name] = true
+		}
+
+		if !remove {
+			s.headers[host].Add(name, value)
+		}
+	}
+}
+
+// Save writes the cookies and headers, readable only by the user since they're usually credentials
+func (s *Session) Save() error {
+	if err := s.Jar.Save(); err != nil {
+		return err
+	}
+
+	dat, err := json.MarshalIndent(s.headers, "", "  ")
+	if err != nil {
+		return fmt.Errorf("could not save the session headers: %s", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(s.dir, "headers.json"), append(dat, '\n'), 0600); err != nil {
+		return fmt.Errorf("could not save the session headers: %s", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,63 @@
+package client
+
+import (
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSession(t *testing.T) {
+	assert := assert.New(t)
+
+	dir := filepath.Join(t.TempDir(), "sessions", "dev")
+	session, err := OpenSession(dir)
+	assert.Nil(err)
+	assert.Empty(session.Headers("api.ex.io"))
+
+	session.Remember("API.ex.io", []string{
+		"x-team: core",
+		"Authorization: Bearer abc",
+		"Content-Type: text/plain",
+		"If-None-Match: \"v1\"",
+		"Cookie: sid=abc",
+	})
+
+	u, _ := url.Parse("https://api.ex.io/login")
+	session.Jar.SetCookies(u, []*http.Cookie{{Name: "sid", Value: "abc"}})
+	assert.Nil(session.Save())
+
+	reopened, err := OpenSession(dir)
+	assert.Nil(err)
+	assert.Equal(http.Header{"X-Team": {"core"}, "Authorization": {"Bearer abc"}}, reopened.Headers("api.ex.io"))
+	assert.Empty(reopened.Headers("other.ex.io"))
+	assert.Equal([]string{"sid=abc"}, cookieNames(reopened.Jar.Cookies(u)))
+
+	info, _ := os.Stat(filepath.Join(dir, "headers.json"))
+	assert.Equal(os.FileMode(0600), info.Mode().Perm())
+}
+
+func TestSessionRememberReplaces(t *testing.T) {
+	assert := assert.New(t)
+
+	session, _ := OpenSession(t.TempDir())
+	session.Remember("api.ex.io", []string{"X-Team: core", "X-Tag: a", "X-Tag: b", "X-Env: dev"})
+
+	// The next run replaces the remembered values and forgets the removed header
+	session.Remember("api.ex.io", []string{"X-Tag: c", "X-Env:", "X-Empty;"})
+	assert.Equal(http.Header{"X-Team": {"core"}, "X-Tag": {"c"}, "X-Empty": {""}}, session.Headers("api.ex.io"))
+}
+
+func TestOpenSessionInvalidHeaders(t *testing.T) {
+	assert := assert.New(t)
+
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "headers.json"), []byte("["), 0600)
+
+	_, err := OpenSession(dir)
+	assert.NotNil(err)
+	assert.Contains(err.Error(), "could not parse the session headers")
+}
@@ -0,0 +1,196 @@
+package client
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"net/http"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/thoom/gulp/config"
+)
+
+// RequestSigner adds a signature to a request once its headers and body are final
+type RequestSigner interface {
+	Sign(req *http.Request, body []byte) error
+}
+
+// hmacAlgorithms are the hashes that can be used with the signing block
+var hmacAlgorithms = map[string]func() hash.Hash{
+	"hmac-sha1":   sha1.New,
+	"hmac-sha256": sha256.New,
+	"hmac-sha512": sha512.New,
+}
+
+// SigningData is passed to the signing templates
+type SigningData struct {
+	Method     string
+	URL        string
+	Host       string
+	Path       string
+	Query      string
+	Timestamp  string
+	Body       string
+	BodySHA256 string
+
+	// Signature is only set for the header value template
+	Signature string
+
+	req *http.Request
+}
+
+// Header returns the value of a request header, ie. {{.Header "Content-Type"}}
+func (d SigningData) Header(name string) string {
+	return d.req.Header.Get(name)
+}
+
+// HMACSigner signs requests with an HMAC of a templated canonical string
+type HMACSigner struct {
+	config    config.Signing
+	hash      func() hash.Hash
+	key       []byte
+	canonical *template.Template
+	value     *template.Template
+
+	now func() time.Time
+}
+
+// signingFuncs are available in the signing templates
+var signingFuncs = template.FuncMap{
+	"lower": strings.ToLower,
+	"upper": strings.ToUpper,
+	"sha256": func(value string) string {
+		return hashHex([]byte(value))
+	},
+	"base64": func(value string) string {
+		return base64.StdEncoding.EncodeToString([]byte(value))
+	},
+}
+
+// NewHMACSigner checks the signing configuration and parses its templates
+func NewHMACSigner(signing config.Signing) (*HMACSigner, error) {
+	if signing.Key == "" || signing.Header == "" || signing.Template == "" {
+		return nil, fmt.Errorf("signing needs a key, a header and a template")
+	}
+
+	algorithm := signing.Algorithm
+	if algorithm == "" {
+		algorithm = "hmac-sha256"
+	}
+
+	h, ok := hmacAlgorithms[algorithm]
+	if !ok {
+		return nil, fmt.Errorf("invalid signing algorithm '%s', expected 'hmac-sha256', 'hmac-sha512' or 'hmac-sha1'", signing.Algorithm)
+	}
+
+	key, err := decodeSigningKey(signing.Key, signing.KeyEncoding)
+	if err != nil {
+		return nil, err
+	}
+
+	canonical, err := template.New("template").Funcs(signingFuncs).Parse(signing.Template)
+	if err != nil {
+		return nil, fmt.Errorf("invalid signing template: %s", err)
+	}
+
+	valueTemplate := signing.Value
+	if valueTemplate == "" {
+		valueTemplate = "{{.Signature}}"
+	}
+
+	value, err := template.New("value").Funcs(signingFuncs).Parse(valueTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("invalid signing value: %s", err)
+	}
+
+	return &HMACSigner{config: signing, hash: h, key: key, canonical: canonical, value: value, now: time.Now}, nil
+}
+
+func decodeSigningKey(key, encoding string) ([]byte, error) {
+	switch encoding {
+	case "", "raw":
+		return []byte(key), nil
+	case "base64":
+		decoded, err := base64.StdEncoding.DecodeString(key)
+		if err != nil {
+			return nil, fmt.Errorf("could not decode the base64 signing key: %s", err)
+		}
+		return decoded, nil
+	case "hex":
+		decoded, err := hex.DecodeString(key)
+		if err != nil {
+			return nil, fmt.Errorf("could not decode the hex signing key: %s", err)
+		}
+		return decoded, nil
+	}
+
+	return nil, fmt.Errorf("invalid signing key_encoding '%s', expected 'raw', 'base64' or 'hex'", encoding)
+}
+
+// Sign sets the timestamp header, if any, and the signature header
+func (s *HMACSigner) Sign(req *http.Request, body []byte) error {
+	if req.Body == nil {
+		body = nil
+	}
+
+	data := SigningData{
+		Method:     req.Method,
+		URL:        req.URL.String(),
+		Host:       req.URL.Host,
+		Path:       req.URL.EscapedPath(),
+		Query:      req.URL.RawQuery,
+		Timestamp:  s.timestamp(),
+		Body:       string(body),
+		BodySHA256: hashHex(body),
+		req:        req,
+	}
+
+	if data.Path == "" {
+		data.Path = "/"
+	}
+
+	if s.config.TimestampHeader != "" {
+		req.Header.Set(s.config.TimestampHeader, data.Timestamp)
+	}
+
+	var canonical strings.Builder
+	if err := s.canonical.Execute(&canonical, data); err != nil {
+		return fmt.Errorf("could not build the string to sign: %s", err)
+	}
+
+	mac := hmac.New(s.hash, s.key)
+	mac.Write([]byte(canonical.String()))
+	if s.config.Encoding == "base64" {
+		data.Signature = base64.StdEncoding.EncodeToString(mac.Sum(nil))
+	} else {
+		data.Signature = hex.EncodeToString(mac.Sum(nil))
+	}
+
+	var value strings.Builder
+	if err := s.value.Execute(&value, data); err != nil {
+		return fmt.Errorf("could not build the signature header: %s", err)
+	}
+
+	req.Header.Set(s.config.Header, value.String())
+	return nil
+}
+
+func (s *HMACSigner) timestamp() string {
+	now := s.now()
+	switch s.config.TimestampFormat {
+	case "unix_ms":
+		return strconv.FormatInt(now.UnixMilli(), 10)
+	case "rfc3339":
+		return now.UTC().Format(time.RFC3339)
+	}
+
+	return strconv.FormatInt(now.Unix(), 10)
+}
@@ -0,0 +1,105 @@
+package client
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/thoom/gulp/config"
+)
+
+func testHMACSigner(t *testing.T, signing config.Signing) *HMACSigner {
+	signer, err := NewHMACSigner(signing)
+	assert.Nil(t, err)
+	signer.now = func() time.Time { return time.Unix(1700000000, 123000000) }
+
+	return signer
+}
+
+func TestHMACSignerSign(t *testing.T) {
+	assert := assert.New(t)
+
+	signer := testHMACSigner(t, config.Signing{
+		Key:             "s3cret",
+		Template:        "{{.Method}}\n{{.Path}}\n{{.Query}}\n{{.Timestamp}}\n{{.BodySHA256}}",
+		Header:          "X-Signature",
+		TimestampHeader: "X-Timestamp",
+	})
+
+	body := []byte(`{"name":"gulp"}`)
+	req, _ := CreateRequest("POST", "https://api.ex.io/users?page=2", body, nil)
+	assert.Nil(signer.Sign(req, body))
+
+	sum := sha256.Sum256(body)
+	mac := hmac.New(sha256.New, []byte("s3cret"))
+	mac.Write([]byte("POST\n/users\npage=2\n1700000000\n" + hex.EncodeToString(sum[:])))
+
+	assert.Equal("1700000000", req.Header.Get("X-Timestamp"))
+	assert.Equal(hex.EncodeToString(mac.Sum(nil)), req.Header.Get("X-Signature"))
+}
+
+func TestHMACSignerValueTemplate(t *testing.T) {
+	assert := assert.New(t)
+
+	key := []byte{0x01, 0x02, 0x03}
+	signer := testHMACSigner(t, config.Signing{
+		Algorithm:       "hmac-sha512",
+		Key:             base64.StdEncoding.EncodeToString(key),
+		KeyEncoding:     "base64",
+		Template:        "{{.Timestamp}}:{{.Header \"X-Client\"}}:{{lower .Method}}",
+		Header:          "Authorization",
+		Value:           `HMAC client="gulp", signature="{{.Signature}}"`,
+		Encoding:        "base64",
+		TimestampFormat: "unix_ms",
+	})
+
+	req, _ := CreateRequest("GET", "https://api.ex.io", nil, http.Header{"X-Client": {"gulp"}})
+	assert.Nil(signer.Sign(req, nil))
+
+	mac := hmac.New(sha512.New, key)
+	mac.Write([]byte("1700000000123:gulp:get"))
+	assert.Equal(`HMAC client="gulp", signature="`+base64.StdEncoding.EncodeToString(mac.Sum(nil))+`"`, req.Header.Get("Authorization"))
+}
+
+func TestHMACSignerTimestampFormats(t *testing.T) {
+	assert := assert.New(t)
+
+	signer := testHMACSigner(t, config.Signing{Key: "k", Template: "x", Header: "X-Signature", TimestampFormat: "rfc3339"})
+	assert.Equal("2023-11-14T22:13:20Z", signer.timestamp())
+
+	signer.config.TimestampFormat = ""
+	assert.Equal("1700000000", signer.timestamp())
+}
+
+func TestHMACSignerTemplateError(t *testing.T) {
+	assert := assert.New(t)
+
+	signer := testHMACSigner(t, config.Signing{Key: "k", Template: "{{.Missing}}", Header: "X-Signature"})
+	req, _ := http.NewRequest("GET", "https://api.ex.io", nil)
+	err := signer.Sign(req, nil)
+	assert.NotNil(err)
+	assert.Contains(fmt.Sprintf("%s", err), "could not build the string to sign")
+}
+
+func TestNewHMACSignerErrors(t *testing.T) {
+	assert := assert.New(t)
+
+	_, err := NewHMACSigner(config.Signing{Key: "k", Header: "X-Signature"})
+	assert.Equal("signing needs a key, a header and a template", fmt.Sprintf("%s", err))
+
+	_, err = NewHMACSigner(config.Signing{Key: "k", Header: "X-Signature", Template: "x", Algorithm: "md5"})
+	assert.Equal("invalid signing algorithm 'md5', expected 'hmac-sha256', 'hmac-sha512' or 'hmac-sha1'", fmt.Sprintf("%s", err))
+
+	_, err = NewHMACSigner(config.Signing{Key: "zz", KeyEncoding: "hex", Header: "X-Signature", Template: "x"})
+	assert.Contains(fmt.Sprintf("%s", err), "could not decode the hex signing key")
+
+	_, err = NewHMACSigner(config.Signing{Key: "k", Header: "X-Signature", Template: "{{.Method"})
+	assert.Contains(fmt.Sprintf("%s", err), "invalid signing template")
+}
@@ -0,0 +1,288 @@
+package client
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/thoom/gulp/config"
+)
+
+// sigV4Algorithm is the only algorithm supported by Signature Version 4
+const sigV4Algorithm = "AWS4-HMAC-SHA256"
+
+// sigV4UnsignedHeaders are left out of the signature since they're changed or added along the way
+var sigV4UnsignedHeaders = map[string]bool{"authorization": true, "user-agent": true, "x-amzn-trace-id": true, "expect": true}
+
+// AWSCredentials are the keys used to sign requests
+type AWSCredentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+}
+
+// SigV4Signer signs requests with AWS Signature Version 4
+type SigV4Signer struct {
+	Credentials AWSCredentials
+	Region      string
+	Service     string
+
+	now func() time.Time
+}
+
+// NewSigV4Signer resolves the region and credentials. Settings that aren't configured are read
+// from the AWS_* environment variables, then from the shared credentials file.
+func NewSigV4Signer(sigv4 config.AWSSigV4) (*SigV4Signer, error) {
+	if sigv4.Service == "" {
+		return nil, fmt.Errorf("aws_sigv4 needs a service, ie. execute-api or s3")
+	}
+
+	region := firstNonEmpty(sigv4.Region, os.Getenv("AWS_REGION"), os.Getenv("AWS_DEFAULT_REGION"))
+	if region == "" {
+		return nil, fmt.Errorf("aws_sigv4 needs a region, set it in the configuration or with AWS_REGION")
+	}
+
+	creds, err := loadAWSCredentials(sigv4)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SigV4Signer{Credentials: creds, Region: region, Service: sigv4.Service, now: time.Now}, nil
+}
+
+// loadAWSCredentials uses the configured keys, then the environment, then the shared credentials file.
+// A configured profile skips the environment since it was picked on purpose.
+func loadAWSCredentials(sigv4 config.AWSSigV4) (AWSCredentials, error) {
+	if sigv4.AccessKeyID != "" || sigv4.SecretAccessKey != "" {
+		if sigv4.AccessKeyID == "" || sigv4.SecretAccessKey == "" {
+			return AWSCredentials{}, fmt.Errorf("aws_sigv4 needs both an access_key_id and a secret_access_key")
+		}
+
+		return AWSCredentials{sigv4.AccessKeyID, sigv4.SecretAccessKey, sigv4.SessionToken}, nil
+	}
+
+	if sigv4.Profile == "" && os.Getenv("AWS_ACCESS_KEY_ID") != "" && os.Getenv("AWS_SECRET_ACCESS_KEY") != "" {
+		return AWSCredentials{os.Getenv("AWS_ACCESS_KEY_ID"), os.Getenv("AWS_SECRET_ACCESS_KEY"), os.Getenv("AWS_SESSION_TOKEN")}, nil
+	}
+
+	profile := firstNonEmpty(sigv4.Profile, os.Getenv("AWS_PROFILE"), "default")
+	return readSharedCredentials(sharedCredentialsFile(), profile)
+}
+
+// sharedCredentialsFile returns AWS_SHARED_CREDENTIALS_FILE, falling back to ~/.aws/credentials
+func sharedCredentialsFile() string {
+	if file := os.Getenv("AWS_SHARED_CREDENTIALS_FILE"); file != "" {
+		return file
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+
+	return filepath.Join(home, ".aws", "credentials")
+}
+
+// readSharedCredentials reads the profile's keys from an INI formatted credentials file
+func readSharedCredentials(file, profile string) (AWSCredentials, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return AWSCredentials{}, fmt.Errorf("could not find AWS credentials in the configuration, environment or '%s'", file)
+	}
+	defer f.Close()
+
+	var creds AWSCredentials
+	section := ""
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok || section != profile {
+			continue
+		}
+
+		switch strings.ToLower(strings.TrimSpace(key)) {
+		case "aws_access_key_id":
+			creds.AccessKeyID = strings.TrimSpace(value)
+		case "aws_secret_access_key":
+			creds.SecretAccessKey = strings.TrimSpace(value)
+		case "aws_session_token":
+			creds.SessionToken = strings.TrimSpace(value)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return AWSCredentials{}, fmt.Errorf("could not read '%s': %s", file, err)
+	}
+
+	if creds.AccessKeyID == "" || creds.SecretAccessKey == "" {
+		return AWSCredentials{}, fmt.Errorf("could not find AWS credentials for profile '%s' in '%s'", profile, file)
+	}
+
+	return creds, nil
+}
+
+// Sign adds the X-Amz-Date and Authorization headers. The body must be the final payload that is sent.
+func (s *SigV4Signer) Sign(req *http.Request, body []byte) error {
+	t := s.now().UTC()
+	amzDate := t.Format("20060102T150405Z")
+	date := t.Format("20060102")
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	if s.Credentials.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.Credentials.SessionToken)
+	}
+
+	if req.Body == nil {
+		body = nil
+	}
+	payloadHash := hashHex(body)
+
+	// S3 requires the payload hash as a header too
+	if s.Service == "s3" {
+		req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	}
+
+	canonicalHeaders, signedHeaders := sigV4Headers(req)
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		sigV4Path(req, s.Service != "s3"),
+		sigV4Query(req),
+		canonicalHeaders,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := strings.Join([]string{date, s.Region, s.Service, "aws4_request"}, "/")
+	stringToSign := strings.Join([]string{sigV4Algorithm, amzDate, scope, hashHex([]byte(canonicalRequest))}, "\n")
+
+	key := []byte("AWS4" + s.Credentials.SecretAccessKey)
+	for _, part := range []string{date, s.Region, s.Service, "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		sigV4Algorithm, s.Credentials.AccessKeyID, scope, signedHeaders, signature))
+	return nil
+}
+
+// sigV4Path returns the canonical path, built from the path that is sent like the AWS SDK does.
+// S3 uses the path as is, every other service escapes it once more.
+func sigV4Path(req *http.Request, escape bool) string {
+	path := req.URL.EscapedPath()
+	if path == "" {
+		return "/"
+	}
+
+	if !escape {
+		return path
+	}
+
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		segments[i] = sigV4Escape(segment)
+	}
+
+	return strings.Join(segments, "/")
+}
+
+// sigV4Query returns the query parameters sorted by name and value
+func sigV4Query(req *http.Request) string {
+	var params []string
+	for name, values := range req.URL.Query() {
+		for _, value := range values {
+			params = append(params, sigV4Escape(name)+"="+sigV4Escape(value))
+		}
+	}
+	sort.Strings(params)
+
+	return strings.Join(params, "&")
+}
+
+// sigV4Headers returns the canonical headers block (ending with a blank line) and the signed header names
+func sigV4Headers(req *http.Request) (string, string) {
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+
+	headers := map[string]string{"host": host}
+	for name, values := range req.Header {
+		name = strings.ToLower(name)
+		if sigV4UnsignedHeaders[name] {
+			continue
+		}
+
+		trimmed := make([]string, len(values))
+		for i, value := range values {
+			trimmed[i] = strings.Join(strings.Fields(value), " ")
+		}
+		headers[name] = strings.Join(trimmed, ",")
+	}
+
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonical strings.Builder
+	for _, name := range names {
+		canonical.WriteString(name + ":" + headers[name] + "\n")
+	}
+
+	return canonical.String(), strings.Join(names, ";")
+}
+
+// sigV4Escape percent-encodes everything except the RFC 3986 unreserved characters
+func sigV4Escape(value string) string {
+	var sb strings.Builder
+	for _, b := range []byte(value) {
+		if ('A' <= b && b <= 'Z') || ('a' <= b && b <= 'z') || ('0' <= b && b <= '9') || b == '-' || b == '_' || b == '.' || b == '~' {
+			sb.WriteByte(b)
+		} else {
+			fmt.Fprintf(&sb, "%%%02X", b)
+		}
+	}
+
+	return sb.String()
+}
+
+func hashHex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+
+	return ""
+}
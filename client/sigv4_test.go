@@ -0,0 +1,179 @@
+package client
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/thoom/gulp/config"
+)
+
+// testSigner uses the credentials and date from the AWS Signature Version 4 test suite
+func testSigner(service string) *SigV4Signer {
+	return &SigV4Signer{
+		Credentials: AWSCredentials{AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"},
+		Region:      "us-east-1",
+		Service:     service,
+		now:         func() time.Time { return time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC) },
+	}
+}
+
+func TestSigV4SignGetVanilla(t *testing.T) {
+	assert := assert.New(t)
+
+	req, _ := http.NewRequest("GET", "https://example.amazonaws.com/", nil)
+	testSigner("service").Sign(req, nil)
+
+	assert.Equal("20150830T123600Z", req.Header.Get("X-Amz-Date"))
+	assert.Equal("AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date, "+
+		"Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31", req.Header.Get("Authorization"))
+}
+
+func TestSigV4SignQueryOrder(t *testing.T) {
+	assert := assert.New(t)
+
+	req, _ := http.NewRequest("GET", "https://example.amazonaws.com/?Param2=value2&Param1=value1", nil)
+	testSigner("service").Sign(req, nil)
+
+	assert.Contains(req.Header.Get("Authorization"), "Signature=b97d918cfa904a5beff61c982a1b6f458b799221646efd99d3219ec94cdf2500")
+}
+
+func TestSigV4SignPost(t *testing.T) {
+	assert := assert.New(t)
+
+	body := []byte("Param1=value1")
+	req, _ := CreateRequest("POST", "https://example.amazonaws.com/", body, http.Header{"Content-Type": {"application/x-www-form-urlencoded"}})
+	testSigner("service").Sign(req, body)
+
+	assert.Equal("AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=content-type;host;x-amz-date, "+
+		"Signature=ff11897932ad3f4e8b18135d722051e5ac45fc38421b1da7b9d196a0fe09473a", req.Header.Get("Authorization"))
+}
+
+func TestSigV4SignS3(t *testing.T) {
+	assert := assert.New(t)
+
+	body := []byte(`{"name":"gulp"}`)
+	req, _ := CreateRequest("PUT", "http://localhost:9000/bucket/some key.json", body, nil)
+	signer := testSigner("s3")
+	signer.Credentials.SessionToken = "session"
+	signer.Sign(req, body)
+
+	assert.Equal(hashHex(body), req.Header.Get("X-Amz-Content-Sha256"))
+	assert.Equal("session", req.Header.Get("X-Amz-Security-Token"))
+	assert.Contains(req.Header.Get("Authorization"), "SignedHeaders=host;x-amz-content-sha256;x-amz-date;x-amz-security-token,")
+}
+
+func TestSigV4SignSkipsUnsignedHeaders(t *testing.T) {
+	assert := assert.New(t)
+
+	req, _ := CreateRequest("GET", "https://example.amazonaws.com/", nil, http.Header{"User-Agent": {"gulp"}, "Accept": {"application/json"}})
+	testSigner("service").Sign(req, nil)
+
+	assert.Contains(req.Header.Get("Authorization"), "SignedHeaders=accept;host;x-amz-date,")
+}
+
+func TestSigV4Path(t *testing.T) {
+	assert := assert.New(t)
+
+	req, _ := http.NewRequest("GET", "https://example.amazonaws.com/a b/c", nil)
+	assert.Equal("/a%2520b/c", sigV4Path(req, true))
+	assert.Equal("/a%20b/c", sigV4Path(req, false))
+
+	// Reserved characters that Go sends unescaped are only escaped for services other than S3
+	req, _ = http.NewRequest("GET", "https://bucket.s3.amazonaws.com/photos/a+b=c@d:e,f;g!h$i&j'k(l)m*n.jpg", nil)
+	assert.Equal("/photos/a+b=c@d:e,f;g!h$i&j'k(l)m*n.jpg", sigV4Path(req, false))
+	assert.Equal("/photos/a%2Bb%3Dc%40d%3Ae%2Cf%3Bg%21h%24i%26j%27k%28l%29m%2An.jpg", sigV4Path(req, true))
+
+	req, _ = http.NewRequest("GET", "https://abc.execute-api.us-east-1.amazonaws.com/a+b", nil)
+	assert.Equal("/a%2Bb", sigV4Path(req, true))
+
+	// Escaped characters, including slashes, are sent as is and escaped again for other services
+	req, _ = http.NewRequest("GET", "https://bucket.s3.amazonaws.com/a%2Fb/%C3%A9t%C3%A9", nil)
+	assert.Equal("/a%2Fb/%C3%A9t%C3%A9", sigV4Path(req, false))
+	assert.Equal("/a%252Fb/%25C3%25A9t%25C3%25A9", sigV4Path(req, true))
+
+	req, _ = http.NewRequest("GET", "https://example.amazonaws.com", nil)
+	assert.Equal("/", sigV4Path(req, true))
+}
+
+func TestNewSigV4SignerErrors(t *testing.T) {
+	assert := assert.New(t)
+	t.Setenv("AWS_REGION", "")
+	t.Setenv("AWS_DEFAULT_REGION", "")
+
+	_, err := NewSigV4Signer(config.AWSSigV4{Region: "us-east-1"})
+	assert.Equal("aws_sigv4 needs a service, ie. execute-api or s3", fmt.Sprintf("%s", err))
+
+	_, err = NewSigV4Signer(config.AWSSigV4{Service: "s3"})
+	assert.Equal("aws_sigv4 needs a region, set it in the configuration or with AWS_REGION", fmt.Sprintf("%s", err))
+
+	_, err = NewSigV4Signer(config.AWSSigV4{Service: "s3", Region: "us-east-1", AccessKeyID: "AKID"})
+	assert.Equal("aws_sigv4 needs both an access_key_id and a secret_access_key", fmt.Sprintf("%s", err))
+}
+
+func TestNewSigV4SignerConfigured(t *testing.T) {
+	assert := assert.New(t)
+	t.Setenv("AWS_ACCESS_KEY_ID", "ENVKEY")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "envsecret")
+
+	signer, err := NewSigV4Signer(config.AWSSigV4{Service: "execute-api", Region: "eu-west-1", AccessKeyID: "AKID", SecretAccessKey: "secret", SessionToken: "token"})
+	assert.Nil(err)
+	assert.Equal(AWSCredentials{"AKID", "secret", "token"}, signer.Credentials)
+	assert.Equal("eu-west-1", signer.Region)
+	assert.Equal("execute-api", signer.Service)
+}
+
+func TestNewSigV4SignerEnv(t *testing.T) {
+	assert := assert.New(t)
+	t.Setenv("AWS_REGION", "")
+	t.Setenv("AWS_DEFAULT_REGION", "us-west-2")
+	t.Setenv("AWS_ACCESS_KEY_ID", "ENVKEY")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "envsecret")
+	t.Setenv("AWS_SESSION_TOKEN", "")
+
+	signer, err := NewSigV4Signer(config.AWSSigV4{Service: "s3"})
+	assert.Nil(err)
+	assert.Equal(AWSCredentials{AccessKeyID: "ENVKEY", SecretAccessKey: "envsecret"}, signer.Credentials)
+	assert.Equal("us-west-2", signer.Region)
+}
+
+func TestNewSigV4SignerSharedCredentials(t *testing.T) {
+	assert := assert.New(t)
+
+	file := filepath.Join(t.TempDir(), "credentials")
+	os.WriteFile(file, []byte(strings.Join([]string{
+		"[default]",
+		"aws_access_key_id = DEFAULTKEY",
+		"aws_secret_access_key = defaultsecret",
+		"",
+		"# MinIO running locally",
+		"[minio]",
+		"aws_access_key_id=minioadmin",
+		"aws_secret_access_key=miniosecret",
+		"aws_session_token=session",
+	}, "\n")), 0600)
+
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", file)
+	t.Setenv("AWS_ACCESS_KEY_ID", "ENVKEY")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "envsecret")
+	t.Setenv("AWS_PROFILE", "")
+
+	// A configured profile is used even when the environment has keys
+	signer, err := NewSigV4Signer(config.AWSSigV4{Service: "s3", Region: "us-east-1", Profile: "minio"})
+	assert.Nil(err)
+	assert.Equal(AWSCredentials{"minioadmin", "miniosecret", "session"}, signer.Credentials)
+
+	t.Setenv("AWS_ACCESS_KEY_ID", "")
+	signer, err = NewSigV4Signer(config.AWSSigV4{Service: "s3", Region: "us-east-1"})
+	assert.Nil(err)
+	assert.Equal(AWSCredentials{AccessKeyID: "DEFAULTKEY", SecretAccessKey: "defaultsecret"}, signer.Credentials)
+
+	t.Setenv("AWS_PROFILE", "missing")
+	_, err = NewSigV4Signer(config.AWSSigV4{Service: "s3", Region: "us-east-1"})
+	assert.Equal("could not find AWS credentials for profile 'missing' in '"+file+"'", fmt.Sprintf("%s", err))
+}
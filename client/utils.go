@@ -2,6 +2,8 @@ package client
 
 import (
 	"fmt"
+	"net/http"
+	"net/url"
 	"runtime"
 	"strings"
 	"time"
@@ -17,56 +19,185 @@ func init() {
 	defaultVersion = time.Now().Format("20060102.0304PM.MST") + "-SNAPSHOT"
 }
 
-// BuildURL will compute the final URL to use in the request
-func BuildURL(path string, configURL string) (string, error) {
-	url := ""
-
+// BuildURL will compute the final URL to use in the request. The path is joined to the configured URL unless
+// it's a full URL, and the query parameters are added to the URL's own. The defaults are only added when
+// neither the URL nor the query set them.
+func BuildURL(path string, configURL string, query, defaults url.Values) (string, error) {
+	var u *url.URL
 	var err error
-	if strings.HasPrefix(path, "http") {
-		url = path
-	} else if configURL != "" {
-		url = configURL + path
+
+	lower := strings.ToLower(path)
+	switch {
+	case strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://"):
+		if u, err = url.Parse(path); err != nil {
+			return "", fmt.Errorf("invalid URL '%s': %s", path, err)
+		}
+	case configURL != "":
+		if u, err = joinURL(configURL, path); err != nil {
+			return "", err
+		}
+	case path == "":
+		return "", fmt.Errorf("need a URL to make a request")
+	default:
+		return "", fmt.Errorf("invalid URL")
+	}
+
+	addQuery(u, query, defaults)
+	return u.String(), nil
+}
+
+// joinURL appends the path to the base URL's path without doubling the slash between them,
+// keeping the query strings of both. The path is otherwise kept as is, including dot segments and double slashes.
+func joinURL(base, path string) (*url.URL, error) {
+	u, err := url.Parse(base)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL '%s': %s", base, err)
+	}
+
+	// The ./ keeps a colon in the first segment (ie. users:list) from being parsed as a scheme
+	ref, err := url.Parse("./" + path)
+	if err != nil {
+		return nil, fmt.Errorf("invalid path '%s': %s", path, err)
+	}
+
+	if refPath := strings.TrimPrefix(ref.EscapedPath(), "./"); refPath != "" {
+		joined := strings.TrimSuffix(u.EscapedPath(), "/") + "/" + strings.TrimPrefix(refPath, "/")
+		if u.Path, err = url.PathUnescape(joined); err != nil {
+			return nil, fmt.Errorf("invalid path '%s': %s", path, err)
+		}
+		u.RawPath = joined
+	}
+
+	if ref.RawQuery != "" {
+		if u.RawQuery != "" {
+			u.RawQuery += "&"
+		}
+		u.RawQuery += ref.RawQuery
+	}
+
+	if ref.Fragment != "" {
+		u.Fragment, u.RawFragment = ref.Fragment, ref.RawFragment
+	}
+
+	return u, nil
+}
+
+// addQuery appends the parameters to the URL's query string, which is kept as it is
+func addQuery(u *url.URL, query, defaults url.Values) {
+	existing := u.Query()
+	added := url.Values{}
+	for k, v := range query {
+		added[k] = append(added[k], v...)
+	}
+
+	for k, v := range defaults {
+		if _, ok := existing[k]; ok {
+			continue
+		}
+		if _, ok := added[k]; !ok {
+			added[k] = v
+		}
+	}
+
+	if len(added) == 0 {
+		return
+	}
+
+	if u.RawQuery != "" {
+		u.RawQuery += "&"
 	}
+	u.RawQuery += added.Encode()
+}
 
-	if url == "" {
-		if path == "" {
-			err = fmt.Errorf("need a URL to make a request")
-		} else {
-			err = fmt.Errorf("invalid URL")
+// ParseQuery parses the -q parameters as key=value, a key alone is sent with an empty value
+func ParseQuery(params []string) (url.Values, error) {
+	query := url.Values{}
+	for _, param := range params {
+		key, value, _ := strings.Cut(param, "=")
+		if key == "" {
+			return nil, fmt.Errorf("invalid query parameter '%s', expected key=value", param)
 		}
+
+		query.Add(key, value)
+	}
+
+	return query, nil
+}
+
+// URLHost returns the host (including the port, if any) of the URL
+func URLHost(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
 	}
 
-	return url, err
+	return u.Host
 }
 
-// BuildHeaders will return a map[string]string of headers
-func BuildHeaders(reqHeaders []string, configHeaders map[string]string, includeJSON bool) (map[string]string, error) {
-	headers := make(map[string]string)
+// BuildHeaders returns the request headers: the defaults, then the configured headers, then the -H headers.
+// The Content-Type is only set by default when there's a body.
+// The first -H header with a name replaces the configured values and the next ones add values,
+// so that a header can be sent more than once.
+func BuildHeaders(reqHeaders []string, configHeaders http.Header, contentType string) (http.Header, error) {
+	headers := make(http.Header)
 
-	// Set the default User-Agent and Accept type
-	headers["USER-AGENT"] = CreateUserAgent()
-	headers["ACCEPT"] = "application/json;q=1.0, */*;q=0.8"
+	// Set the default User-Agent and Accept type, and the Content-Type of the body
+	headers.Set("User-Agent", CreateUserAgent())
+	headers.Set("Accept", "application/json;q=1.0, */*;q=0.8")
 
-	if includeJSON {
-		headers["CONTENT-TYPE"] = "application/json"
+	if contentType != "" {
+		headers.Set("Content-Type", contentType)
 	}
 
 	for k, v := range configHeaders {
-		headers[strings.ToUpper(k)] = v
+		headers[http.CanonicalHeaderKey(k)] = append([]string(nil), v...)
 	}
 
+	replaced := map[string]bool{}
 	for _, header := range reqHeaders {
-		pieces := strings.Split(header, ":")
-		if len(pieces) != 2 {
-			return nil, fmt.Errorf("could not parse header: '%s'", header)
+		name, value, remove, err := ParseHeader(header)
+		if err != nil {
+			return nil, err
 		}
 
-		headers[strings.ToUpper(pieces[0])] = strings.TrimSpace(pieces[1])
+		if remove || !replaced[name] {
+			headers.Del(name)
+			replaced[name] = true
+		}
+
+		if !remove {
+			headers.Add(name, value)
+		}
+	}
+
+	// Go adds its own User-Agent when there isn't one, but doesn't send an empty one
+	if _, ok := headers["User-Agent"]; !ok {
+		headers["User-Agent"] = []string{""}
 	}
 
 	return headers, nil
 }
 
+// ParseHeader splits a -H header on the first colon, since values can contain colons too (ie. URLs).
+// Like curl, "Name:" without a value removes the header and "Name;" sends it with an empty value.
+func ParseHeader(header string) (name string, value string, remove bool, err error) {
+	name, value, hasColon := strings.Cut(header, ":")
+	if !hasColon {
+		var empty bool
+		if name, empty = strings.CutSuffix(strings.TrimSpace(header), ";"); !empty {
+			return "", "", false, fmt.Errorf("could not parse header: '%s'", header)
+		}
+	}
+
+	name = strings.TrimSpace(name)
+	if name == "" || strings.ContainsAny(name, " \t") {
+		return "", "", false, fmt.Errorf("could not parse header: '%s'", header)
+	}
+
+	value = strings.TrimSpace(value)
+	return http.CanonicalHeaderKey(name), value, hasColon && value == "", nil
+}
+
 // GetVersion builds the version from the build branch
 func GetVersion() string {
 	version := buildVersion
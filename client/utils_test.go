@@ -2,6 +2,8 @@ package client
 
 import (
 	"fmt"
+	"net/http"
+	"net/url"
 	"runtime"
 	"strings"
 	"testing"
@@ -15,87 +17,155 @@ import (
 func TestBuildHeadersBase(t *testing.T) {
 	assert := assert.New(t)
 
-	headers, _ := BuildHeaders([]string{"X-Test-Key: abc123def"}, nil, false)
+	headers, _ := BuildHeaders([]string{"X-Test-Key: abc123def"}, nil, "")
 	assert.Equal(3, len(headers))
 
-	assert.Contains(headers, "USER-AGENT")
-	assert.Equal(CreateUserAgent(), headers["USER-AGENT"])
+	assert.Contains(headers, "User-Agent")
+	assert.Equal(CreateUserAgent(), headers.Get("User-Agent"))
 
-	assert.Contains(headers, "ACCEPT")
-	assert.Equal("application/json;q=1.0, */*;q=0.8", headers["ACCEPT"])
+	assert.Contains(headers, "Accept")
+	assert.Equal("application/json;q=1.0, */*;q=0.8", headers.Get("Accept"))
 
-	assert.Contains(headers, "X-TEST-KEY")
-	assert.Equal("abc123def", headers["X-TEST-KEY"])
+	assert.Contains(headers, "X-Test-Key")
+	assert.Equal("abc123def", headers.Get("X-Test-Key"))
 }
 
 func TestBuildHeadersJSON(t *testing.T) {
 	assert := assert.New(t)
 
-	headers, _ := BuildHeaders([]string{}, nil, true)
+	headers, _ := BuildHeaders([]string{}, nil, "application/json")
 	assert.Equal(3, len(headers))
 
-	assert.Contains(headers, "CONTENT-TYPE")
-	assert.Equal("application/json", headers["CONTENT-TYPE"])
+	assert.Contains(headers, "Content-Type")
+	assert.Equal("application/json", headers.Get("Content-Type"))
 }
 
 func TestBuildHeadersHeaderConfig(t *testing.T) {
 	assert := assert.New(t)
 
-	configHeaders := map[string]string{}
-	configHeaders["X-Test-Key"] = "abc123def"
+	configHeaders := http.Header{}
+	configHeaders["x-test-key"] = []string{"abc123def"}
+	configHeaders["Accept"] = []string{"application/json", "text/plain"}
 
-	headers, _ := BuildHeaders([]string{}, configHeaders, false)
+	headers, _ := BuildHeaders([]string{}, configHeaders, "")
 	assert.Equal(3, len(headers))
 
-	assert.Contains(headers, "X-TEST-KEY")
-	assert.Equal("abc123def", headers["X-TEST-KEY"])
+	assert.Contains(headers, "X-Test-Key")
+	assert.Equal("abc123def", headers.Get("X-Test-Key"))
+	assert.Equal([]string{"application/json", "text/plain"}, headers.Values("Accept"))
 }
 
 func TestBuildHeadersHeaderOverride(t *testing.T) {
 	assert := assert.New(t)
 
-	headers, _ := BuildHeaders([]string{"Content-Type: application/vnd.ex.v1+json"}, nil, true)
+	headers, _ := BuildHeaders([]string{"Content-Type: application/vnd.ex.v1+json"}, nil, "application/json")
 	assert.Equal(3, len(headers))
 
-	assert.Contains(headers, "CONTENT-TYPE")
-	assert.Equal("application/vnd.ex.v1+json", headers["CONTENT-TYPE"])
+	assert.Contains(headers, "Content-Type")
+	assert.Equal("application/vnd.ex.v1+json", headers.Get("Content-Type"))
+}
+
+func TestBuildHeadersRepeated(t *testing.T) {
+	assert := assert.New(t)
+
+	configHeaders := http.Header{"Accept": {"text/html"}, "X-Team": {"core"}}
+	headers, err := BuildHeaders([]string{"Accept: application/json", "X-Tag: a", "accept: text/plain", "X-Tag: b"}, configHeaders, "")
+	assert.Nil(err)
+
+	// The first -H header replaces the configured values, the next ones are added
+	assert.Equal([]string{"application/json", "text/plain"}, headers.Values("Accept"))
+	assert.Equal([]string{"a", "b"}, headers.Values("X-Tag"))
+	assert.Equal([]string{"core"}, headers.Values("X-Team"))
+}
+
+func TestBuildHeadersEmptyAndRemoved(t *testing.T) {
+	assert := assert.New(t)
+
+	configHeaders := http.Header{"X-Team": {"core"}}
+	headers, err := BuildHeaders([]string{"X-Empty;", "X-Team:", "Accept:", "X-Later: a", "X-Later:"}, configHeaders, "")
+	assert.Nil(err)
+
+	assert.Equal([]string{""}, headers.Values("X-Empty"))
+	assert.NotContains(headers, "X-Team")
+	assert.NotContains(headers, "Accept")
+	assert.NotContains(headers, "X-Later")
+
+	// An empty User-Agent keeps Go from sending its own
+	headers, err = BuildHeaders([]string{"User-Agent:"}, nil, "")
+	assert.Nil(err)
+	assert.Equal([]string{""}, headers["User-Agent"])
+
+	headers, err = BuildHeaders([]string{"User-Agent:", "User-Agent: curl/8.0"}, nil, "")
+	assert.Nil(err)
+	assert.Equal([]string{"curl/8.0"}, headers["User-Agent"])
+}
+
+func TestParseHeader(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		header string
+		name   string
+		value  string
+		remove bool
+	}{
+		{"x-url: http://a.ex.io:8080/", "X-Url", "http://a.ex.io:8080/", false},
+		{"X-Empty;", "X-Empty", "", false},
+		{" X-Empty ; ", "X-Empty", "", false},
+		{"User-Agent:", "User-Agent", "", true},
+		{"User-Agent:  ", "User-Agent", "", true},
+		{"X-Note: a;b", "X-Note", "a;b", false},
+	}
+
+	for _, test := range tests {
+		name, value, remove, err := ParseHeader(test.header)
+		assert.Nil(err, test.header)
+		assert.Equal(test.name, name, test.header)
+		assert.Equal(test.value, value, test.header)
+		assert.Equal(test.remove, remove, test.header)
+	}
+
+	for _, header := range []string{"X-Missing", ": value", ";", "X Space: value", "X-Semi; value"} {
+		_, _, _, err := ParseHeader(header)
+		assert.Equal(fmt.Sprintf("could not parse header: '%s'", header), fmt.Sprintf("%s", err))
+	}
 }
 
 func TestBuildHeadersHeaderErr(t *testing.T) {
 	assert := assert.New(t)
 
-	_, err := BuildHeaders([]string{"Bad-Content-Header"}, nil, true)
+	_, err := BuildHeaders([]string{"Bad-Content-Header"}, nil, "application/json")
 	assert.NotNil(err)
 	assert.Equal("could not parse header: 'Bad-Content-Header'", fmt.Sprintf("%s", err))
 }
 
 func TestBuildURLBasic(t *testing.T) {
 	assert := assert.New(t)
-	url, _ := BuildURL("/some/resource", "https://api.ex.io")
+	url, _ := BuildURL("/some/resource", "https://api.ex.io", nil, nil)
 	assert.Equal("https://api.ex.io/some/resource", url)
 }
 
 func TestBuildURLNoConfig(t *testing.T) {
 	assert := assert.New(t)
-	url, _ := BuildURL("https://api.ex.io/some/resource", "")
+	url, _ := BuildURL("https://api.ex.io/some/resource", "", nil, nil)
 	assert.Equal("https://api.ex.io/some/resource", url)
 }
 
 func TestBuildURLOverride(t *testing.T) {
 	assert := assert.New(t)
-	url, _ := BuildURL("https://api.ex.io", "https://another.base.io")
+	url, _ := BuildURL("https://api.ex.io", "https://another.base.io", nil, nil)
 	assert.Equal("https://api.ex.io", url)
 }
 
 func TestBuildURLNoPath(t *testing.T) {
 	assert := assert.New(t)
-	url, _ := BuildURL("", "https://api.ex.io")
+	url, _ := BuildURL("", "https://api.ex.io", nil, nil)
 	assert.Equal("https://api.ex.io", url)
 }
 
 func TestBuildURLBadURL(t *testing.T) {
 	assert := assert.New(t)
-	url, err := BuildURL("/bad/path", "")
+	url, err := BuildURL("/bad/path", "", nil, nil)
 	assert.Empty(url)
 	assert.NotNil(err)
 	assert.Equal("invalid URL", fmt.Sprintf("%s", err))
@@ -103,12 +173,79 @@ func TestBuildURLBadURL(t *testing.T) {
 
 func TestBuildURLNoURL(t *testing.T) {
 	assert := assert.New(t)
-	url, err := BuildURL("", "")
+	url, err := BuildURL("", "", nil, nil)
 	assert.Empty(url)
 	assert.NotNil(err)
 	assert.Equal("need a URL to make a request", fmt.Sprintf("%s", err))
 }
 
+func TestBuildURLJoin(t *testing.T) {
+	assert := assert.New(t)
+
+	// The slashes between the base URL and the path aren't doubled
+	url, _ := BuildURL("/users/", "https://api.ex.io/v1/", nil, nil)
+	assert.Equal("https://api.ex.io/v1/users/", url)
+
+	// The path itself isn't cleaned
+	url, _ = BuildURL("users//1", "https://api.ex.io/v1", nil, nil)
+	assert.Equal("https://api.ex.io/v1/users//1", url)
+
+	url, _ = BuildURL("/a/../b/./c", "https://api.ex.io/v1/", nil, nil)
+	assert.Equal("https://api.ex.io/v1/a/../b/./c", url)
+
+	// A colon in the first segment isn't a scheme
+	url, _ = BuildURL("users:list", "https://api.ex.io/v1/", nil, nil)
+	assert.Equal("https://api.ex.io/v1/users:list", url)
+
+	url, _ = BuildURL("/files/a%2Fb%20c", "https://api.ex.io", nil, nil)
+	assert.Equal("https://api.ex.io/files/a%2Fb%20c", url)
+
+	// Existing query strings are kept as they are
+	url, _ = BuildURL("/users?page=2&q=a+b", "https://api.ex.io/v1?key=abc", nil, nil)
+	assert.Equal("https://api.ex.io/v1/users?key=abc&page=2&q=a+b", url)
+
+	url, _ = BuildURL("?page=2", "https://api.ex.io/v1", nil, nil)
+	assert.Equal("https://api.ex.io/v1?page=2", url)
+
+	url, _ = BuildURL("HTTPS://api.ex.io/users", "https://another.base.io", nil, nil)
+	assert.Equal("https://api.ex.io/users", url)
+
+	_, err := BuildURL("/users", "https://api.ex.io/%zz", nil, nil)
+	assert.Contains(fmt.Sprintf("%s", err), "invalid URL 'https://api.ex.io/%zz'")
+}
+
+func TestBuildURLQuery(t *testing.T) {
+	assert := assert.New(t)
+
+	query := url.Values{"q": {"a b&c"}, "tag": {"x", "y"}}
+	u, err := BuildURL("/users?page=2&tag=w", "https://api.ex.io", query, nil)
+	assert.Nil(err)
+	assert.Equal("https://api.ex.io/users?page=2&tag=w&q=a+b%26c&tag=x&tag=y", u)
+
+	// The existing query string isn't encoded again
+	u, _ = BuildURL("/users?z=1&c&sig=a%2Fb", "https://api.ex.io", url.Values{"a": {"1"}}, url.Values{"c": {"2"}})
+	assert.Equal("https://api.ex.io/users?z=1&c&sig=a%2Fb&a=1", u)
+
+	// Defaults are only added when the URL and query don't set them
+	defaults := url.Values{"page": {"1"}, "q": {"all"}, "version": {"2"}}
+	u, _ = BuildURL("https://api.ex.io/users?page=2", "", url.Values{"q": {"gulp"}}, defaults)
+	assert.Equal("https://api.ex.io/users?page=2&q=gulp&version=2", u)
+
+	u, _ = BuildURL("https://api.ex.io/users#top", "", nil, url.Values{"version": {"2"}})
+	assert.Equal("https://api.ex.io/users?version=2#top", u)
+}
+
+func TestParseQuery(t *testing.T) {
+	assert := assert.New(t)
+
+	query, err := ParseQuery([]string{"q=a b&c", "tag=x", "tag=y", "debug", "expr=a=b"})
+	assert.Nil(err)
+	assert.Equal(url.Values{"q": {"a b&c"}, "tag": {"x", "y"}, "debug": {""}, "expr": {"a=b"}}, query)
+
+	_, err = ParseQuery([]string{"=value"})
+	assert.Equal("invalid query parameter '=value', expected key=value", fmt.Sprintf("%s", err))
+}
+
 func TestGetVersion(t *testing.T) {
 	assert := assert.New(t)
 
@@ -140,7 +277,23 @@ func TestCreateUserAgent(t *testing.T) {
 func TestBuildClientAgent(t *testing.T) {
 	assert := assert.New(t)
 
-	res := BuildClientAuth("test.pem", "test.key", "", config.New.ClientAuth)
+	res := BuildClientAuth("test.pem", "test.key", "", "", config.New.ClientAuth)
 	assert.Equal("test.pem", res.Cert)
 	assert.Equal("test.key", res.Key)
 }
+
+func TestURLHost(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal("api.ex.io:8443", URLHost("https://api.ex.io:8443/users?id=1"))
+	assert.Equal("api.ex.io", URLHost("http://api.ex.io"))
+	assert.Equal("", URLHost("://bad"))
+}
+
+func TestBuildHeadersValueWithColon(t *testing.T) {
+	assert := assert.New(t)
+
+	headers, err := BuildHeaders([]string{"Authorization: Basic YWRtaW46czNjcmV0", "Referer: https://ex.io:8443/path"}, nil, "")
+	assert.Nil(err)
+	assert.Equal("Basic YWRtaW46czNjcmV0", headers.Get("Authorization"))
+	assert.Equal("https://ex.io:8443/path", headers.Get("Referer"))
+}
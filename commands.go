@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"github.com/thoom/gulp/config"
+	"github.com/thoom/gulp/output"
+)
+
+// isCommand checks whether the arguments start with one of the subcommands.
+// When -url is passed, the arguments are a request path, even if it's literally config or init.
+func isCommand(urlFlag string, args []string) bool {
+	return urlFlag == "" && len(args) > 0 && (args[0] == "config" || args[0] == "init")
+}
+
+// runCommand runs a subcommand and returns the exit code
+func runCommand(args []string, bo *output.BuffOut) int {
+	if args[0] == "init" {
+		if err := flag.CommandLine.Parse(args[1:]); err != nil {
+			return 1
+		}
+
+		return initConfig(os.Stdin, bo)
+	}
+
+	if len(args) < 2 {
+		bo.PrintErr("", fmt.Errorf("missing config subcommand: expected 'validate', 'show' or 'schema'"))
+		return 1
+	}
+
+	// Allow flags after the subcommand, ie. gulp config show -p prod
+	if err := flag.CommandLine.Parse(args[2:]); err != nil {
+		return 1
+	}
+
+	switch args[1] {
+	case "validate":
+		return validateConfig(bo)
+	case "show":
+		return showConfig(bo)
+	case "schema":
+		return printSchema(bo)
+	}
+
+	bo.PrintErr("", fmt.Errorf("unknown config subcommand '%s': expected 'validate', 'show' or 'schema'", args[1]))
+	return 1
+}
+
+// validateConfig reports every problem found in the configuration files
+func validateConfig(bo *output.BuffOut) int {
+	files, problems := config.Validate(*configFlag)
+	if len(files) == 0 {
+		bo.PrintWarning("No configuration files found")
+		return 0
+	}
+
+	if len(problems) > 0 {
+		for _, p := range problems {
+			bo.PrintErr("", fmt.Errorf("%s", p))
+		}
+		bo.PrintStoplight(fmt.Sprintf("Found %d problem(s) in %s", len(problems), strings.Join(files, ", ")), true)
+		return 1
+	}
+
+	bo.PrintStoplight("Configuration is valid: "+strings.Join(files, ", "), false)
+	return 0
+}
+
+// showConfig prints the effective configuration with secrets masked
+func showConfig(bo *output.BuffOut) int {
+	loadedConfig, err := config.LoadConfiguration(*configFlag)
+	if err != nil {
+		bo.PrintErr("", err)
+		return 1
+	}
+
+	loadedConfig, err = loadedConfig.ApplyProfile(*profileFlag)
+	if err != nil {
+		bo.PrintErr("", err)
+		return 1
+	}
+
+	redacted := loadedConfig.Redacted()
+	dat, err := yaml.Marshal(redacted)
+	if err != nil {
+		bo.PrintErr("could not display configuration", err)
+		return 1
+	}
+
+	for _, source := range redacted.Sources {
+		fmt.Fprintf(bo.Out, "# %s\n", source)
+	}
+
+	if *profileFlag != "" {
+		fmt.Fprintf(bo.Out, "# profile: %s\n", *profileFlag)
+	}
+
+	fmt.Fprint(bo.Out, string(dat))
+	return 0
+}
+
+// printSchema prints the JSON Schema of the configuration file
+func printSchema(bo *output.BuffOut) int {
+	dat, err := json.MarshalIndent(config.ConfigSchema(), "", "  ")
+	if err != nil {
+		bo.PrintErr("could not display schema", err)
+		return 1
+	}
+
+	fmt.Fprintln(bo.Out, string(dat))
+	return 0
+}
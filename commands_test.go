@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/thoom/gulp/config"
+	"github.com/thoom/gulp/output"
+)
+
+func TestIsCommand(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.True(isCommand("", []string{"config", "show"}))
+	assert.True(isCommand("", []string{"init"}))
+	assert.False(isCommand("", []string{"/config"}))
+	assert.False(isCommand("", []string{}))
+
+	// With -url, the argument is a request path
+	assert.False(isCommand("https://api.ex.io", []string{"config"}))
+	assert.False(isCommand("https://api.ex.io", []string{"init"}))
+}
+
+func TestRunCommandMissingSubcommand(t *testing.T) {
+	assert := assert.New(t)
+	output.NoColor(true)
+
+	b := &bytes.Buffer{}
+	bo := &output.BuffOut{Out: b, Err: b}
+	assert.Equal(1, runCommand([]string{"config"}, bo))
+	assert.Equal("missing config subcommand: expected 'validate', 'show' or 'schema'\n", b.String())
+
+	b.Reset()
+	assert.Equal(1, runCommand([]string{"config", "bogus"}, bo))
+	assert.Equal("unknown config subcommand 'bogus': expected 'validate', 'show' or 'schema'\n", b.String())
+}
+
+func TestRunCommandValidate(t *testing.T) {
+	assert := assert.New(t)
+	output.NoColor(true)
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	testFile := filepath.Join(t.TempDir(), "gulp.yml")
+	os.WriteFile(testFile, []byte("url: https://api.ex.io\ntimeout: 30x"), 0644)
+	*configFlag = testFile
+	defer func() { *configFlag = config.DefaultFileName }()
+
+	b := &bytes.Buffer{}
+	bo := &output.BuffOut{Out: b, Err: b}
+	assert.Equal(1, runCommand([]string{"config", "validate"}, bo))
+	assert.Equal(testFile+":2: invalid timeout '30x' for 'timeout', expected a duration (ie. 500ms, 1m30s) or a number of seconds\nFound 1 problem(s) in "+testFile+"\n", b.String())
+
+	os.WriteFile(testFile, []byte("url: https://api.ex.io\ntimeout: 30"), 0644)
+	b.Reset()
+	assert.Equal(0, runCommand([]string{"config", "validate"}, bo))
+	assert.Equal("Configuration is valid: "+testFile+"\n", b.String())
+}
+
+func TestRunCommandShow(t *testing.T) {
+	assert := assert.New(t)
+	output.NoColor(true)
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	testFile := filepath.Join(t.TempDir(), "gulp.yml")
+	os.WriteFile(testFile, []byte(`
+url: https://api.ex.io
+headers:
+  Authorization: Bearer abc123def
+auth:
+  username: admin
+  password: s3cret
+profiles:
+  prod:
+    url: https://prod.ex.io
+`), 0644)
+	*configFlag = testFile
+	defer func() { *configFlag = config.DefaultFileName }()
+
+	b := &bytes.Buffer{}
+	bo := &output.BuffOut{Out: b, Err: b}
+	assert.Equal(0, runCommand([]string{"config", "show", "-p", "prod"}, bo))
+	*profileFlag = ""
+
+	assert.Equal(`# `+testFile+`
+# profile: prod
+auth:
+  password: '********'
+  username: admin
+client_auth:
+  ca: ""
+  cert: ""
+  key: ""
+display: ""
+flags:
+  follow_redirects: true
+  use_color: true
+  verify_tls: true
+headers:
+  Authorization: Bearer ********
+timeout: 5m0s
+url: https://prod.ex.io
+`, b.String())
+}
+
+func TestRunCommandSchema(t *testing.T) {
+	assert := assert.New(t)
+
+	b := &bytes.Buffer{}
+	bo := &output.BuffOut{Out: b, Err: b}
+	assert.Equal(0, runCommand([]string{"config", "schema"}, bo))
+
+	// The shipped schema must match the structs, regenerate it with: gulp config schema > gulp.schema.json
+	shipped, err := os.ReadFile("gulp.schema.json")
+	assert.Nil(err)
+	assert.Equal(string(shipped), b.String())
+}
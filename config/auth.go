@@ -0,0 +1,80 @@
+package config
+
+import "strings"
+
+// Auth contains the credentials used to build the Authorization header or an API key.
+// Secrets can reference ${VAR} or $(file:path) so they don't need to be in the file.
+type Auth struct {
+	Username string  `json:"username,omitempty" description:"The username used for basic auth"`
+	Password string  `json:"password,omitempty" description:"The password used for basic auth"`
+	Bearer   string  `json:"bearer,omitempty" description:"The token sent as a bearer token in the Authorization header"`
+	APIKey   *APIKey `json:"api_key,omitempty" description:"An API key sent as a header or query parameter"`
+	Digest   *Bool   `json:"digest,omitempty" validate:"bool" description:"Answer Digest challenges with the username and password instead of sending basic auth"`
+}
+
+// APIKey is sent as a header (default) or as a query parameter
+type APIKey struct {
+	Name  string `json:"name" description:"The header or query parameter name, ie. X-Api-Key"`
+	Value string `json:"value" description:"The API key"`
+	In    string `json:"in,omitempty" validate:"enum=header|query" description:"Where the key is sent: header (default) or query"`
+}
+
+// APIKeyInQuery is used when an API key is sent as a query parameter
+const APIKeyInQuery = "query"
+
+// UseBasic checks whether basic auth credentials are set
+func (a *Auth) UseBasic() bool {
+	return a.Username != "" || a.Password != ""
+}
+
+// UseDigest checks whether the username and password are used for digest auth
+func (a *Auth) UseDigest() bool {
+	return a.Digest.Get(false)
+}
+
+// merge overlays the credentials set in override
+func (a *Auth) merge(override Auth) {
+	if override.Username != "" {
+		a.Username = override.Username
+	}
+
+	if override.Password != "" {
+		a.Password = override.Password
+	}
+
+	if override.Bearer != "" {
+		a.Bearer = override.Bearer
+	}
+
+	if override.APIKey != nil {
+		apiKey := *override.APIKey
+		a.APIKey = &apiKey
+	}
+
+	if override.Digest != nil {
+		a.Digest = override.Digest.copy()
+	}
+}
+
+// redacted masks the secrets
+func (a Auth) redacted() Auth {
+	a.Password = maskValue(a.Password)
+	a.Bearer = maskValue(a.Bearer)
+	if a.APIKey != nil {
+		apiKey := *a.APIKey
+		apiKey.Value = maskValue(apiKey.Value)
+		a.APIKey = &apiKey
+	}
+
+	return a
+}
+
+func (a *Auth) trimSpace() {
+	a.Username = strings.TrimSpace(a.Username)
+	a.Bearer = strings.TrimSpace(a.Bearer)
+	if a.APIKey != nil {
+		a.APIKey.Name = strings.TrimSpace(a.APIKey.Name)
+		a.APIKey.Value = strings.TrimSpace(a.APIKey.Value)
+		a.APIKey.In = strings.TrimSpace(a.APIKey.In)
+	}
+}
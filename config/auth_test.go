@@ -0,0 +1,87 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuthUseBasic(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.False((&Auth{}).UseBasic())
+	assert.False((&Auth{Bearer: "abc123def"}).UseBasic())
+	assert.True((&Auth{Username: "admin"}).UseBasic())
+	assert.True((&Auth{Password: "s3cret"}).UseBasic())
+}
+
+func TestAuthMerge(t *testing.T) {
+	assert := assert.New(t)
+
+	config := &Config{Auth: Auth{Username: "admin", Password: "s3cret", APIKey: &APIKey{Name: "X-Api-Key", Value: "abc"}}}
+	config.Merge(&Config{Auth: Auth{Password: "other", APIKey: &APIKey{Name: "key", Value: "def", In: "query"}}})
+
+	assert.Equal("admin", config.Auth.Username)
+	assert.Equal("other", config.Auth.Password)
+	assert.Equal(&APIKey{Name: "key", Value: "def", In: "query"}, config.Auth.APIKey)
+}
+
+func TestAuthMergeCopiesAPIKey(t *testing.T) {
+	assert := assert.New(t)
+
+	override := &Config{Auth: Auth{APIKey: &APIKey{Name: "X-Api-Key", Value: "abc"}}}
+	config := &Config{}
+	config.Merge(override)
+	config.Auth.APIKey.Value = "changed"
+
+	assert.Equal("abc", override.Auth.APIKey.Value)
+}
+
+func TestAuthRedacted(t *testing.T) {
+	assert := assert.New(t)
+
+	config := &Config{Auth: Auth{Username: "admin", Password: "s3cret pass", Bearer: "abc123def", APIKey: &APIKey{Name: "X-Api-Key", Value: "abc"}}}
+	redacted := config.Redacted()
+
+	assert.Equal("admin", redacted.Auth.Username)
+	assert.Equal("********", redacted.Auth.Password)
+	assert.Equal("********", redacted.Auth.Bearer)
+	assert.Equal("X-Api-Key", redacted.Auth.APIKey.Name)
+	assert.Equal("********", redacted.Auth.APIKey.Value)
+
+	// The original shouldn't be modified
+	assert.Equal("abc", config.Auth.APIKey.Value)
+}
+
+func TestAuthTrimSpace(t *testing.T) {
+	assert := assert.New(t)
+
+	auth := Auth{Username: " admin ", Password: " s3cret ", APIKey: &APIKey{Name: " X-Api-Key ", Value: " abc ", In: " query "}}
+	auth.trimSpace()
+
+	assert.Equal("admin", auth.Username)
+	assert.Equal(" s3cret ", auth.Password)
+	assert.Equal(&APIKey{Name: "X-Api-Key", Value: "abc", In: "query"}, auth.APIKey)
+}
+
+func TestAuthValidate(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Empty(validateData("test.yml", []byte("auth:\n  username: admin\n  password: ${PASSWORD:-s3cret}\n  api_key:\n    name: key\n    value: abc\n    in: query\n")))
+
+	problems := validateData("test.yml", []byte("auth:\n  api_key:\n    name: key\n    value: abc\n    in: body\n"))
+	assert.Len(problems, 1)
+	assert.Equal("test.yml:5: invalid value 'body' for 'auth.api_key.in', expected 'header' or 'query'", problems[0].String())
+}
+
+func TestAuthMergeDigest(t *testing.T) {
+	assert := assert.New(t)
+
+	config := &Config{}
+	config.Merge(&Config{Auth: Auth{Username: "admin", Digest: NewBool(true)}})
+	assert.True(config.Auth.UseDigest())
+
+	config.Merge(&Config{Auth: Auth{Digest: NewBool(false)}})
+	assert.False(config.Auth.UseDigest())
+	assert.False((&Auth{}).UseDigest())
+}
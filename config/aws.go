@@ -0,0 +1,57 @@
+package config
+
+import "strings"
+
+// AWSSigV4 contains the settings used to sign requests with AWS Signature Version 4.
+// Credentials that aren't set are read from the environment or the shared credentials file.
+type AWSSigV4 struct {
+	Region          string `json:"region,omitempty" description:"The AWS region, defaults to AWS_REGION or AWS_DEFAULT_REGION"`
+	Service         string `json:"service" description:"The signing name of the service, ie. execute-api or s3"`
+	AccessKeyID     string `json:"access_key_id,omitempty" description:"The access key ID, defaults to AWS_ACCESS_KEY_ID or the shared credentials file"`
+	SecretAccessKey string `json:"secret_access_key,omitempty" description:"The secret access key"`
+	SessionToken    string `json:"session_token,omitempty" description:"The session token used with temporary credentials"`
+	Profile         string `json:"profile,omitempty" description:"The profile in the shared credentials file, defaults to AWS_PROFILE or default"`
+}
+
+// merge overlays the settings set in override
+func (a *AWSSigV4) merge(override *AWSSigV4) {
+	if override.Region != "" {
+		a.Region = override.Region
+	}
+
+	if override.Service != "" {
+		a.Service = override.Service
+	}
+
+	if override.AccessKeyID != "" {
+		a.AccessKeyID = override.AccessKeyID
+	}
+
+	if override.SecretAccessKey != "" {
+		a.SecretAccessKey = override.SecretAccessKey
+	}
+
+	if override.SessionToken != "" {
+		a.SessionToken = override.SessionToken
+	}
+
+	if override.Profile != "" {
+		a.Profile = override.Profile
+	}
+}
+
+// redacted masks the secrets
+func (a AWSSigV4) redacted() *AWSSigV4 {
+	a.SecretAccessKey = maskValue(a.SecretAccessKey)
+	a.SessionToken = maskValue(a.SessionToken)
+	return &a
+}
+
+func (a *AWSSigV4) trimSpace() {
+	a.Region = strings.TrimSpace(a.Region)
+	a.Service = strings.TrimSpace(a.Service)
+	a.AccessKeyID = strings.TrimSpace(a.AccessKeyID)
+	a.SecretAccessKey = strings.TrimSpace(a.SecretAccessKey)
+	a.SessionToken = strings.TrimSpace(a.SessionToken)
+	a.Profile = strings.TrimSpace(a.Profile)
+}
@@ -0,0 +1,39 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAWSSigV4Merge(t *testing.T) {
+	assert := assert.New(t)
+
+	config := &Config{}
+	config.Merge(&Config{AWSSigV4: &AWSSigV4{Region: "us-east-1", Service: "execute-api", Profile: "dev"}})
+	config.Merge(&Config{AWSSigV4: &AWSSigV4{Region: "eu-west-1", AccessKeyID: "AKID", SecretAccessKey: "secret"}})
+	config.Merge(&Config{})
+
+	assert.Equal(&AWSSigV4{Region: "eu-west-1", Service: "execute-api", Profile: "dev", AccessKeyID: "AKID", SecretAccessKey: "secret"}, config.AWSSigV4)
+}
+
+func TestAWSSigV4Redacted(t *testing.T) {
+	assert := assert.New(t)
+
+	config := &Config{AWSSigV4: &AWSSigV4{Service: "s3", AccessKeyID: "AKID", SecretAccessKey: "secret", SessionToken: "token"}}
+	redacted := config.Redacted()
+
+	assert.Equal("AKID", redacted.AWSSigV4.AccessKeyID)
+	assert.Equal("********", redacted.AWSSigV4.SecretAccessKey)
+	assert.Equal("********", redacted.AWSSigV4.SessionToken)
+	assert.Equal("secret", config.AWSSigV4.SecretAccessKey)
+}
+
+func TestAWSSigV4Validate(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Empty(validateData("test.yml", []byte("aws_sigv4:\n  region: us-east-1\n  service: s3\n  profile: minio\n")))
+
+	problems := validateData("test.yml", []byte("aws_sigv4:\n  service: s3\n  secret_key: abc\n"))
+	assert.Len(problems, 1)
+}
@@ -0,0 +1,124 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Bool is a boolean flag that accepts the common spellings (ie. yes/no, on/off, 1/0).
+// Flags are stored as *Bool so that a flag missing from the configuration (nil) can be told apart from false.
+type Bool struct {
+	value bool
+
+	// reference holds a value like ${VERIFY_TLS} until it is resolved along with the rest of the configuration
+	reference string
+}
+
+// boolSpellings are the values accepted for a flag, in lower case. Title and upper case are accepted too.
+var boolSpellings = map[string]bool{
+	"true": true, "yes": true, "y": true, "on": true, "1": true,
+	"false": false, "no": false, "n": false, "off": false, "0": false,
+}
+
+// NewBool returns a flag that is set to the value
+func NewBool(value bool) *Bool {
+	return &Bool{value: value}
+}
+
+// ParseBool converts one of the accepted spellings into a boolean
+func ParseBool(value string) (bool, error) {
+	value = strings.TrimSpace(value)
+	lower := strings.ToLower(value)
+	if b, ok := boolSpellings[lower]; ok && (value == lower || value == strings.ToUpper(value) || value == strings.ToUpper(lower[:1])+lower[1:]) {
+		return b, nil
+	}
+
+	return false, fmt.Errorf("invalid boolean '%s', expected true or false", value)
+}
+
+// Get returns the flag's value, or the default if it isn't set
+func (b *Bool) Get(def bool) bool {
+	if b == nil {
+		return def
+	}
+
+	return b.value
+}
+
+// copy returns a new flag with the same value, so that resolving one doesn't change the other
+func (b *Bool) copy() *Bool {
+	if b == nil {
+		return nil
+	}
+
+	c := *b
+	return &c
+}
+
+// UnmarshalJSON accepts booleans, the numbers 1 and 0, and strings with any of the accepted spellings.
+// References are kept as they are until the configuration is resolved.
+func (b *Bool) UnmarshalJSON(data []byte) error {
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+
+	if s, ok := value.(string); ok && strings.Contains(s, "$") {
+		*b = Bool{reference: s}
+		return nil
+	}
+
+	parsed, err := ParseBool(fmt.Sprint(value))
+	if err != nil {
+		return err
+	}
+
+	*b = Bool{value: parsed}
+	return nil
+}
+
+// MarshalJSON writes the flag as a boolean, or as the reference if it hasn't been resolved
+func (b Bool) MarshalJSON() ([]byte, error) {
+	if b.reference != "" {
+		return json.Marshal(b.reference)
+	}
+
+	return json.Marshal(b.value)
+}
+
+// interpolate resolves the reference and parses the result
+func (b *Bool) interpolate() error {
+	if b.reference == "" {
+		return nil
+	}
+
+	resolved, err := Interpolate(b.reference)
+	if err != nil {
+		return err
+	}
+
+	parsed, err := ParseBool(resolved)
+	if err != nil {
+		return err
+	}
+
+	*b = Bool{value: parsed}
+	return nil
+}
+
+// boolEnum lists every accepted string spelling for the JSON Schema
+func boolEnum() []interface{} {
+	var enum []interface{}
+	seen := map[string]bool{}
+	for _, value := range []string{"true", "false", "yes", "no", "y", "n", "on", "off", "1", "0"} {
+		for _, spelling := range []string{value, strings.ToUpper(value[:1]) + value[1:], strings.ToUpper(value)} {
+			if !seen[spelling] {
+				seen[spelling] = true
+				enum = append(enum, spelling)
+			}
+		}
+	}
+
+	return enum
+}
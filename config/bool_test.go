@@ -0,0 +1,107 @@
+package config
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseBool(t *testing.T) {
+	assert := assert.New(t)
+
+	for value, expected := range map[string]bool{
+		"true": true, "True": true, "TRUE": true, "yes": true, "Y": true, "on": true, "ON": true, "1": true, " true ": true,
+		"false": false, "False": false, "no": false, "NO": false, "n": false, "off": false, "Off": false, "0": false,
+	} {
+		b, err := ParseBool(value)
+		assert.Nil(err, value)
+		assert.Equal(expected, b, value)
+	}
+
+	for _, value := range []string{"", "maybe", "tRUE", "2", "enabled"} {
+		_, err := ParseBool(value)
+		assert.EqualError(err, "invalid boolean '"+value+"', expected true or false")
+	}
+}
+
+func TestBoolGet(t *testing.T) {
+	assert := assert.New(t)
+
+	var unset *Bool
+	assert.True(unset.Get(true))
+	assert.False(unset.Get(false))
+	assert.False(NewBool(false).Get(true))
+	assert.True(NewBool(true).Get(false))
+}
+
+func TestBoolUnmarshalJSON(t *testing.T) {
+	assert := assert.New(t)
+
+	var flags ConfigFlags
+	assert.Nil(json.Unmarshal([]byte(`{"follow_redirects": false, "use_color": "no", "verify_tls": 1}`), &flags))
+	assert.Equal(NewBool(false), flags.FollowRedirects)
+	assert.Equal(NewBool(false), flags.UseColor)
+	assert.Equal(NewBool(true), flags.VerifyTLS)
+
+	flags = ConfigFlags{}
+	assert.Nil(json.Unmarshal([]byte(`{"use_color": null}`), &flags))
+	assert.Nil(flags.UseColor)
+
+	err := json.Unmarshal([]byte(`{"verify_tls": "sometimes"}`), &flags)
+	assert.EqualError(err, "invalid boolean 'sometimes', expected true or false")
+
+	dat, _ := json.Marshal(ConfigFlags{UseColor: NewBool(false)})
+	assert.Equal(`{"use_color":false}`, string(dat))
+}
+
+func TestLoadConfigurationFlagSpellings(t *testing.T) {
+	assert := assert.New(t)
+	t.Setenv("GULP_TEST_VERIFY", "off")
+
+	testFile := filepath.Join(t.TempDir(), "gulp.yml")
+	os.WriteFile(testFile, []byte(`flags:
+  follow_redirects: "No"
+  use_color: 0
+  verify_tls: ${GULP_TEST_VERIFY}
+profiles:
+  ci:
+    flags:
+      use_color: ${GULP_TEST_MISSING_COLOR:-yes}
+`), 0644)
+
+	config, err := LoadConfiguration(testFile)
+	assert.Nil(err)
+	assert.False(config.FollowRedirects())
+	assert.False(config.UseColor())
+	assert.False(config.VerifyTLS())
+
+	ci, err := config.ApplyProfile("ci")
+	assert.Nil(err)
+	assert.True(ci.UseColor())
+	assert.False(ci.FollowRedirects())
+}
+
+func TestLoadConfigurationInvalidFlag(t *testing.T) {
+	assert := assert.New(t)
+
+	testFile := filepath.Join(t.TempDir(), "gulp.yml")
+	os.WriteFile(testFile, []byte("url: https://api.ex.io\nflags:\n  verify_tls: \"sometimes\"\n"), 0644)
+
+	_, err := LoadConfiguration(testFile)
+	assert.EqualError(err, "invalid configuration:\n  "+testFile+":3: invalid boolean 'sometimes' for 'flags.verify_tls', expected true or false")
+}
+
+func TestApplyProfileInvalidFlagReference(t *testing.T) {
+	assert := assert.New(t)
+	t.Setenv("GULP_TEST_COLOR", "sometimes")
+
+	config := &Config{Profiles: map[string]*Config{"ci": {Flags: ConfigFlags{UseColor: &Bool{reference: "${GULP_TEST_COLOR}"}}}}}
+	_, err := config.ApplyProfile("ci")
+	assert.EqualError(err, "could not resolve profile 'ci': invalid boolean 'sometimes', expected true or false")
+
+	// The profile itself isn't resolved
+	assert.Equal("${GULP_TEST_COLOR}", config.Profiles["ci"].Flags.UseColor.reference)
+}
@@ -1,50 +1,177 @@
 package config
 
 import (
+	"encoding/json"
 	"fmt"
+	"net/url"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/ghodss/yaml"
+	yamlv3 "gopkg.in/yaml.v3"
 )
 
-// Config contains configuration data
+// Config contains configuration data.
+// The description tags are used to build the JSON Schema of the configuration file (see ConfigSchema).
 type Config struct {
-	URL        string            `json:"url"`
-	Headers    map[string]string `json:"headers"`
-	Display    string            `json:"display"`
-	Timeout    string            `json:"timeout"`
-	ClientAuth ClientAuth        `json:"client_auth"`
-	Flags      ConfigFlags       `json:"flags"`
+	URL     string    `json:"url" description:"The URL used when a request only passes a path"`
+	Headers HeaderMap `json:"headers" description:"Headers sent with every request, override them with -H"`
+	Query   QueryMap  `json:"query,omitempty" description:"Query parameters added to every request, unless the URL or -q sets them"`
+	Display string    `json:"display" validate:"display" description:"How responses are displayed (default is the response body only)"`
+	Timeout string    `json:"timeout" validate:"timeout" description:"How long to wait for the whole request, as a duration (ie. 1m30s) or a number of seconds"`
+
+	ConnectTimeout        string `json:"connect_timeout,omitempty" validate:"timeout" description:"How long to wait for the connection to be established"`
+	TLSHandshakeTimeout   string `json:"tls_handshake_timeout,omitempty" validate:"timeout" description:"How long to wait for the TLS handshake to complete"`
+	ResponseHeaderTimeout string `json:"response_header_timeout,omitempty" validate:"timeout" description:"How long to wait for the response headers once the request has been sent"`
+
+	ClientAuth ClientAuth         `json:"client_auth" description:"The client certificate and key used for client cert auth, and a custom CA"`
+	Auth       Auth               `json:"auth" description:"Credentials used for basic auth, a bearer token or an API key"`
+	OAuth2     *OAuth2            `json:"oauth2,omitempty" description:"An OAuth2 client used to fetch the bearer token sent with requests"`
+	AWSSigV4   *AWSSigV4          `json:"aws_sigv4,omitempty" description:"Sign requests with AWS Signature Version 4"`
+	Signing    *Signing           `json:"signing,omitempty" description:"Add an HMAC signature header to each request"`
+	JWT        *JWT               `json:"jwt,omitempty" description:"Sign a short-lived JWT for each request"`
+	Flags      ConfigFlags        `json:"flags" description:"Options that are enabled by default and can be disabled"`
+	Profiles   map[string]*Config `json:"profiles,omitempty" description:"Named configurations merged over the base configuration with -p"`
+	Hosts      map[string]*Host   `json:"hosts,omitempty" description:"Settings applied to requests for hosts matching the hostname or glob"`
+	Extends    StringList         `json:"extends,omitempty" description:"Shared configuration files to load first, relative to this file"`
+
+	CredentialHelper string `json:"credential_helper,omitempty" description:"A command that prints the headers to add to each request as JSON, ie. from a secret store"`
+	Body             string `json:"body,omitempty" description:"The default body of requests that send one when stdin is empty, as inline JSON/YAML or a @file"`
+
+	// Sources lists the configuration files that were merged, from lowest to highest precedence
+	Sources []string `json:"-"`
 }
 
-// ClientAuth leads to files with PEM-encoded data tied to client cert authentication
+// ClientAuth leads to files with PEM-encoded data tied to client cert authentication.
+// The cert can also be a PKCS#12 (.p12 or .pfx) file that contains the key.
 type ClientAuth struct {
-	Cert string `json:"cert"`
-	Key  string `json:"key"`
-	CA   string `json:"ca"`
+	Cert       string `json:"cert" validate:"file" description:"The client certificate file or inline PEM content, or a PKCS#12 (.p12/.pfx) file that includes the key"`
+	Key        string `json:"key" validate:"file" description:"The client certificate key file or inline PEM content, which can be an encrypted PKCS#8 key"`
+	Passphrase string `json:"passphrase,omitempty" description:"The passphrase for the PKCS#12 file or encrypted key, prompted for when it's needed and missing"`
+	CA         string `json:"ca" validate:"file" description:"The CA certificate file or inline PEM content used to verify the server"`
 }
 
 // ConfigFlags contains valid configuration flags
-// These are strings not bool bc otherwise we don't know if the config file is missing the flag or is set to false
+// These are pointers so that we know if the config file is missing the flag or is set to false
 type ConfigFlags struct {
-	FollowRedirects string `json:"follow_redirects"`
-	UseColor        string `json:"use_color"`
-	VerifyTLS       string `json:"verify_tls"`
+	FollowRedirects *Bool `json:"follow_redirects,omitempty" validate:"bool" description:"Follow 3XX redirects, disable with -no-redirect"`
+	UseColor        *Bool `json:"use_color,omitempty" validate:"bool" description:"Colorize verbose responses, disable with -no-color"`
+	VerifyTLS       *Bool `json:"verify_tls,omitempty" validate:"bool" description:"Verify TLS certificates, disable with -insecure"`
+}
+
+// StringList accepts either a single string or a list of strings.
+// Numbers and booleans are kept as they're written, ie. for header values.
+type StringList []string
+
+// UnmarshalJSON converts a single value into a list with one item
+func (sl *StringList) UnmarshalJSON(data []byte) error {
+	var list []json.RawMessage
+	if err := json.Unmarshal(data, &list); err != nil {
+		list = []json.RawMessage{data}
+	}
+
+	values := make(StringList, len(list))
+	for i, item := range list {
+		var value interface{}
+		if err := json.Unmarshal(item, &value); err != nil {
+			return fmt.Errorf("expected a string or a list of strings")
+		}
+
+		switch v := value.(type) {
+		case string:
+			values[i] = v
+		case float64, bool:
+			values[i] = string(item)
+		default:
+			return fmt.Errorf("expected a string or a list of strings")
+		}
+	}
+
+	*sl = values
+	return nil
+}
+
+// MarshalJSON writes a list with one item as a single string, the way it's usually written
+func (sl StringList) MarshalJSON() ([]byte, error) {
+	if len(sl) == 1 {
+		return json.Marshal(sl[0])
+	}
+
+	return json.Marshal([]string(sl))
+}
+
+// HeaderMap maps header names to a value, or a list of values that are each sent
+type HeaderMap map[string]StringList
+
+// merge replaces the headers set in override by name (case-insensitive), returning a new map
+func (hm HeaderMap) merge(override HeaderMap) HeaderMap {
+	headers := make(HeaderMap, len(hm)+len(override))
+	for k, v := range hm {
+		headers[k] = v
+	}
+
+	for k, v := range override {
+		for existing := range headers {
+			if strings.EqualFold(existing, k) {
+				delete(headers, existing)
+			}
+		}
+		headers[k] = append(StringList(nil), v...)
+	}
+
+	return headers
+}
+
+// QueryMap maps query parameter names to a value, or a list of values that are each sent
+type QueryMap map[string]StringList
+
+// merge replaces the parameters set in override by name, returning a new map
+func (qm QueryMap) merge(override QueryMap) QueryMap {
+	query := make(QueryMap, len(qm)+len(override))
+	for k, v := range qm {
+		query[k] = v
+	}
+
+	for k, v := range override {
+		query[k] = append(StringList(nil), v...)
+	}
+
+	return query
+}
+
+// Values returns the parameters as url.Values
+func (qm QueryMap) Values() url.Values {
+	values := make(url.Values, len(qm))
+	for k, v := range qm {
+		values[k] = append([]string(nil), v...)
+	}
+
+	return values
+}
+
+// Timeouts contains the limits for each phase of a request.
+// A zero value means that phase isn't limited separately from the overall timeout.
+type Timeouts struct {
+	Overall        time.Duration
+	Connect        time.Duration
+	TLSHandshake   time.Duration
+	ResponseHeader time.Duration
 }
 
 // DefaultTimeout is 5 minutes (300 seconds)
-const DefaultTimeout = 300
+const DefaultTimeout = 300 * time.Second
 
 // New creates a default configuration object
 var New *Config
 
 func newConfig() *Config {
 	flags := ConfigFlags{
-		FollowRedirects: "true",
-		UseColor:        "true",
-		VerifyTLS:       "true",
+		FollowRedirects: NewBool(true),
+		UseColor:        NewBool(true),
+		VerifyTLS:       NewBool(true),
 	}
 
 	return &Config{Flags: flags}
@@ -52,70 +179,319 @@ func newConfig() *Config {
 
 // FollowRedirects determines whether or not to follow 301/302 redirects
 func (gc *Config) FollowRedirects() bool {
-	return gc.Flags.FollowRedirects != "false"
+	return gc.Flags.FollowRedirects.Get(true)
 }
 
 // UseColor adds a switch for whether or not to colorize the output
 func (gc *Config) UseColor() bool {
-	return gc.Flags.UseColor != "false"
+	return gc.Flags.UseColor.Get(true)
 }
 
 // VerifyTLS determines whether or not to verify that a TLS cert is valid
 func (gc *Config) VerifyTLS() bool {
-	return gc.Flags.VerifyTLS != "false"
+	return gc.Flags.VerifyTLS.Get(true)
 }
 
 func (gc *ClientAuth) UseAuth() bool {
-	return strings.TrimSpace(gc.Cert) != "" && strings.TrimSpace(gc.Key) != ""
+	return strings.TrimSpace(gc.Cert) != "" && (strings.TrimSpace(gc.Key) != "" || gc.IsPKCS12())
+}
+
+// IsPKCS12 determines whether the cert is a PKCS#12 file, which includes the key
+func (gc *ClientAuth) IsPKCS12() bool {
+	ext := strings.ToLower(filepath.Ext(strings.TrimSpace(gc.Cert)))
+	return ext == ".p12" || ext == ".pfx"
 }
 
 // GetTimeout Parses the config string and returns the default if the value wasn't passed
-func (gc *Config) GetTimeout() int {
-	// If the timeout is empty, just return 300
+func (gc *Config) GetTimeout() time.Duration {
+	// If the timeout is empty, just return 300 seconds
 	if gc.Timeout == "" {
 		return DefaultTimeout
 	}
 
-	i, err := strconv.Atoi(gc.Timeout)
+	d, err := ParseTimeout(gc.Timeout)
 	if err != nil {
-		// For now, if the timeout is not valid, then return 300
+		// Invalid values are reported when the configuration is loaded
 		return DefaultTimeout
 	}
 
-	return i
+	return d
+}
+
+// GetTimeouts returns the overall timeout along with the limits for the connect, TLS handshake and response header phases
+func (gc *Config) GetTimeouts() Timeouts {
+	timeouts := Timeouts{Overall: gc.GetTimeout()}
+	timeouts.Connect, _ = ParseTimeout(gc.ConnectTimeout)
+	timeouts.TLSHandshake, _ = ParseTimeout(gc.TLSHandshakeTimeout)
+	timeouts.ResponseHeader, _ = ParseTimeout(gc.ResponseHeaderTimeout)
+
+	return timeouts
+}
+
+// ParseTimeout parses a Go duration string (ie. 500ms, 1m30s). A plain number is treated as seconds.
+// An empty value returns 0.
+func ParseTimeout(value string) (time.Duration, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, nil
+	}
+
+	if i, err := strconv.Atoi(value); err == nil {
+		value = strconv.Itoa(i) + "s"
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid duration '%s'", value)
+	}
+
+	return d, nil
+}
+
+// Merge overlays any values set in override on top of the current configuration.
+// Headers are merged by name (case-insensitive) and query parameters by name, everything else is replaced only if set.
+func (gc *Config) Merge(override *Config) {
+	if override == nil {
+		return
+	}
+
+	if override.URL != "" {
+		gc.URL = override.URL
+	}
+
+	if len(override.Headers) > 0 {
+		gc.Headers = gc.Headers.merge(override.Headers)
+	}
+
+	if len(override.Query) > 0 {
+		gc.Query = gc.Query.merge(override.Query)
+	}
+
+	if override.Display != "" {
+		gc.Display = override.Display
+	}
+
+	if override.Timeout != "" {
+		gc.Timeout = override.Timeout
+	}
+
+	if override.ConnectTimeout != "" {
+		gc.ConnectTimeout = override.ConnectTimeout
+	}
+
+	if override.TLSHandshakeTimeout != "" {
+		gc.TLSHandshakeTimeout = override.TLSHandshakeTimeout
+	}
+
+	if override.ResponseHeaderTimeout != "" {
+		gc.ResponseHeaderTimeout = override.ResponseHeaderTimeout
+	}
+
+	if override.ClientAuth.Cert != "" {
+		gc.ClientAuth.Cert = override.ClientAuth.Cert
+	}
+
+	if override.ClientAuth.Key != "" {
+		gc.ClientAuth.Key = override.ClientAuth.Key
+	}
+
+	if override.ClientAuth.Passphrase != "" {
+		gc.ClientAuth.Passphrase = override.ClientAuth.Passphrase
+	}
+
+	if override.ClientAuth.CA != "" {
+		gc.ClientAuth.CA = override.ClientAuth.CA
+	}
+
+	gc.Auth.merge(override.Auth)
+
+	if override.OAuth2 != nil {
+		if gc.OAuth2 == nil {
+			gc.OAuth2 = &OAuth2{}
+		}
+		gc.OAuth2.merge(override.OAuth2)
+	}
+
+	if override.AWSSigV4 != nil {
+		if gc.AWSSigV4 == nil {
+			gc.AWSSigV4 = &AWSSigV4{}
+		}
+		gc.AWSSigV4.merge(override.AWSSigV4)
+	}
+
+	if override.Signing != nil {
+		if gc.Signing == nil {
+			gc.Signing = &Signing{}
+		}
+		gc.Signing.merge(override.Signing)
+	}
+
+	if override.JWT != nil {
+		if gc.JWT == nil {
+			gc.JWT = &JWT{}
+		}
+		gc.JWT.merge(override.JWT)
+	}
+
+	if override.CredentialHelper != "" {
+		gc.CredentialHelper = override.CredentialHelper
+	}
+
+	if override.Body != "" {
+		gc.Body = override.Body
+	}
+
+	if override.Flags.FollowRedirects != nil {
+		gc.Flags.FollowRedirects = override.Flags.FollowRedirects.copy()
+	}
+
+	if override.Flags.UseColor != nil {
+		gc.Flags.UseColor = override.Flags.UseColor.copy()
+	}
+
+	if override.Flags.VerifyTLS != nil {
+		gc.Flags.VerifyTLS = override.Flags.VerifyTLS.copy()
+	}
+
+	if len(override.Profiles) > 0 {
+		profiles := make(map[string]*Config, len(gc.Profiles)+len(override.Profiles))
+		for k, v := range gc.Profiles {
+			profiles[k] = v
+		}
+
+		for k, v := range override.Profiles {
+			if existing, ok := profiles[k]; ok && existing != nil {
+				merged := &Config{}
+				merged.Merge(existing)
+				merged.Merge(v)
+				v = merged
+			}
+			profiles[k] = v
+		}
+		gc.Profiles = profiles
+	}
+
+	if len(override.Hosts) > 0 {
+		hosts := make(map[string]*Host, len(gc.Hosts)+len(override.Hosts))
+		for k, v := range gc.Hosts {
+			hosts[k] = v
+		}
+
+		for k, v := range override.Hosts {
+			if existing, ok := hosts[k]; ok && existing != nil && v != nil {
+				merged := &Host{}
+				merged.merge(existing)
+				merged.merge(v)
+				v = merged
+			}
+			hosts[k] = v
+		}
+		gc.Hosts = hosts
+	}
+}
+
+// ApplyProfile returns a copy of the configuration with the named profile merged on top of it.
+// If the name is empty, the configuration is returned unchanged.
+func (gc *Config) ApplyProfile(name string) (*Config, error) {
+	if name == "" {
+		return gc, nil
+	}
+
+	profile, ok := gc.Profiles[name]
+	if !ok || profile == nil {
+		return nil, fmt.Errorf("could not find profile '%s' in the configuration", name)
+	}
+
+	resolved := &Config{}
+	resolved.Merge(profile)
+	resolved.Profiles = nil
+	if err := resolved.expandReferences(); err != nil {
+		return nil, fmt.Errorf("could not resolve profile '%s': %v", name, err)
+	}
+	resolved.trimSpace()
+
+	merged := &Config{Sources: gc.Sources}
+	merged.Merge(gc)
+	merged.Merge(resolved)
+	return merged, nil
 }
 
 func init() {
 	New = newConfig()
 }
 
-// LoadConfiguration builds a configuration object based on the fileName passed
+// LoadConfiguration builds a configuration object by merging, from lowest to highest precedence,
+// the user-level configuration, the nearest project .gulp.yml and the fileName passed (if not the default)
 func LoadConfiguration(fileName string) (*Config, error) {
-	dat, err := os.ReadFile(fileName)
-	if err != nil {
-		// If the file wasn't found and it's just the default, don't worry about it.
-		if fileName == ".gulp.yml" {
-			return New, nil
+	files := discoverFiles(fileName)
+
+	// If no files were found, don't worry about it.
+	if len(files) == 0 {
+		return New, nil
+	}
+
+	gulpConfig := &Config{}
+	for _, f := range files {
+		fileConfig, err := loadFile(f, nil)
+		if err != nil {
+			return nil, err
 		}
 
-		return nil, fmt.Errorf("could not load configuration '%s'", fileName)
+		gulpConfig.Merge(fileConfig)
+		gulpConfig.Sources = append(gulpConfig.Sources, fileConfig.Sources...)
+	}
+
+	return gulpConfig, nil
+}
+
+// loadFile parses and resolves a single configuration file along with any files it extends.
+// The chain contains the files that included this one and is used to detect cycles.
+func loadFile(fileName string, chain []string) (*Config, error) {
+	chain, err := extendChain(chain, fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	dat, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("could not load configuration '%s'%s", fileName, includeChain(chain))
 	}
 
-	var gulpConfig *Config
+	gulpConfig := &Config{}
 	if err := yaml.Unmarshal(dat, &gulpConfig); err != nil {
-		return nil, fmt.Errorf(`could not parse configuration file '%s': %v
+		// If the YAML itself is valid, point to the line with the invalid value
+		var root yamlv3.Node
+		if yamlv3.Unmarshal(dat, &root) == nil {
+			if problems := validateData(fileName, dat); len(problems) > 0 {
+				return nil, &ValidationError{Problems: problems}
+			}
+		}
+
+		return nil, fmt.Errorf(`could not parse configuration file '%s'%s: %v
 
 Example of valid YAML configuration:
 ---
+# Optional shared configuration files to extend (relative to this file)
+extends: ../shared/gulp.yml
+
 # Basic configuration
 url: https://api.example.com
-timeout: "30"
+timeout: 30s
+
+# Optional limits for the individual phases of the request
+connect_timeout: 5s
+tls_handshake_timeout: 10s
+response_header_timeout: 30s
 
 # Optional request headers
 headers:
   Authorization: Bearer your-token-here
   X-Custom-Header: some-value
 
+# Optional query parameters
+query:
+  api-version: 2
+
 # Optional client certificate authentication
 client_auth:
   cert: /path/to/client-cert.pem
@@ -124,31 +500,171 @@ client_auth:
 
 # Optional flags (all default to true)
 flags:
-  follow_redirects: "true"
-  use_color: "true"
-  verify_tls: "true"
+  follow_redirects: true
+  use_color: true
+  verify_tls: true
 
 # Optional display setting
 display: verbose  # or "status-code-only"
+
+# Optional default body for POST/PUT/PATCH requests, inline or a @file
+body: "@payloads/user.yml"
+
+# Optional settings for specific hosts (hostnames or globs)
+hosts:
+  "*.internal.example.com":
+    headers:
+      X-Internal: "true"
+    client_auth:
+      cert: /path/to/internal-cert.pem
+      key: /path/to/internal-key.pem
+
+# Optional named profiles, selected with the -p flag
+profiles:
+  staging:
+    url: https://staging.api.example.com
 ---
 
-For more examples, see: https://github.com/thoom/gulp#configuration`, fileName, err)
+To create a new configuration file, run: gulp init
+For more examples, see: https://github.com/thoom/gulp#configuration`, fileName, includeChain(chain), err)
 	}
 
-	// Clean up spaced padding
-	if gulpConfig.ClientAuth.Cert != "" {
-		gulpConfig.ClientAuth.Cert = strings.TrimSpace(gulpConfig.ClientAuth.Cert)
+	if problems := validateData(fileName, dat); len(problems) > 0 {
+		return nil, &ValidationError{Problems: problems}
 	}
 
-	// Clean up spaced padding
-	if gulpConfig.ClientAuth.Key != "" {
-		gulpConfig.ClientAuth.Key = strings.TrimSpace(gulpConfig.ClientAuth.Key)
+	if err := gulpConfig.expandReferences(); err != nil {
+		return nil, fmt.Errorf("could not resolve configuration '%s'%s: %v", fileName, includeChain(chain), err)
 	}
 
-	// Clean up spaced padding
-	if gulpConfig.ClientAuth.CA != "" {
-		gulpConfig.ClientAuth.CA = strings.TrimSpace(gulpConfig.ClientAuth.CA)
+	gulpConfig.trimSpace()
+	gulpConfig.resolvePaths(filepath.Dir(fileName))
+
+	if len(gulpConfig.Extends) == 0 {
+		gulpConfig.Sources = []string{fileName}
+		return gulpConfig, nil
 	}
 
-	return gulpConfig, nil
+	// Parent files are merged in order, with this file's values on top
+	merged := &Config{}
+	for _, parent := range gulpConfig.Extends {
+		parentConfig, err := loadFile(extendsPath(fileName, parent), chain)
+		if err != nil {
+			return nil, err
+		}
+
+		merged.Merge(parentConfig)
+		merged.Sources = append(merged.Sources, parentConfig.Sources...)
+	}
+
+	merged.Merge(gulpConfig)
+	merged.Sources = append(merged.Sources, fileName)
+	return merged, nil
+}
+
+// extendChain adds the file to the chain of files that included it, returning an error if it creates a cycle
+func extendChain(chain []string, fileName string) ([]string, error) {
+	abs, err := filepath.Abs(fileName)
+	if err != nil {
+		abs = fileName
+	}
+
+	for _, included := range chain {
+		if included == abs {
+			return nil, fmt.Errorf("configuration include cycle detected: %s", strings.Join(append(chain, abs), " -> "))
+		}
+	}
+
+	return append(chain[:len(chain):len(chain)], abs), nil
+}
+
+// extendsPath resolves a parent path relative to the file that extends it
+func extendsPath(fileName, parent string) string {
+	parent = strings.TrimSpace(parent)
+	if filepath.IsAbs(parent) {
+		return parent
+	}
+
+	return filepath.Join(filepath.Dir(fileName), parent)
+}
+
+// resolvePath makes a relative file reference relative to the directory of the configuration file that contains it.
+// Inline PEM content, absolute paths and references that haven't been expanded yet (ie. in profiles) are left as they are.
+func resolvePath(dir, value string) string {
+	value = strings.TrimSpace(value)
+	if value == "" || filepath.IsAbs(value) || strings.HasPrefix(value, "-----BEGIN") || strings.Contains(value, "${") || strings.Contains(value, "$(") {
+		return value
+	}
+
+	return filepath.Join(dir, value)
+}
+
+// resolvePaths resolves the client_auth files, the jwt key and a @file body against the configuration file's directory,
+// so that the configuration can be used from any directory
+func (gc *Config) resolvePaths(dir string) {
+	gc.ClientAuth.resolvePaths(dir)
+	if gc.JWT != nil {
+		gc.JWT.Key = resolvePath(dir, gc.JWT.Key)
+	}
+	if path, ok := strings.CutPrefix(gc.Body, "@"); ok {
+		gc.Body = "@" + resolvePath(dir, strings.TrimSpace(path))
+	}
+
+	for _, h := range gc.Hosts {
+		if h != nil {
+			h.ClientAuth.resolvePaths(dir)
+		}
+	}
+	for _, p := range gc.Profiles {
+		if p != nil {
+			p.resolvePaths(dir)
+		}
+	}
+}
+
+// resolvePaths resolves the cert, key and CA files against the configuration file's directory
+func (gc *ClientAuth) resolvePaths(dir string) {
+	gc.Cert = resolvePath(dir, gc.Cert)
+	gc.Key = resolvePath(dir, gc.Key)
+	gc.CA = resolvePath(dir, gc.CA)
+}
+
+// includeChain describes how a file was reached through extends, if it was included by another file
+func includeChain(chain []string) string {
+	if len(chain) < 2 {
+		return ""
+	}
+
+	return fmt.Sprintf(" (include chain: %s)", strings.Join(chain, " -> "))
+}
+
+// trimSpace cleans up spaced padding around the client auth values, including those in host blocks
+func (gc *Config) trimSpace() {
+	gc.ClientAuth.trimSpace()
+	gc.Auth.trimSpace()
+	if gc.OAuth2 != nil {
+		gc.OAuth2.trimSpace()
+	}
+	if gc.AWSSigV4 != nil {
+		gc.AWSSigV4.trimSpace()
+	}
+	if gc.Signing != nil {
+		gc.Signing.trimSpace()
+	}
+	if gc.JWT != nil {
+		gc.JWT.trimSpace()
+	}
+	gc.CredentialHelper = strings.TrimSpace(gc.CredentialHelper)
+	for _, h := range gc.Hosts {
+		if h != nil {
+			h.ClientAuth.trimSpace()
+		}
+	}
+}
+
+// trimSpace cleans up spaced padding around the cert, key and CA values. The passphrase is used as is.
+func (gc *ClientAuth) trimSpace() {
+	gc.Cert = strings.TrimSpace(gc.Cert)
+	gc.Key = strings.TrimSpace(gc.Key)
+	gc.CA = strings.TrimSpace(gc.CA)
 }
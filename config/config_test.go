@@ -1,13 +1,27 @@
 package config
 
 import (
+	"encoding/json"
 	"fmt"
+	"net/url"
 	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
 
+// Keep a user-level configuration on the machine from leaking into the tests
+func TestMain(m *testing.M) {
+	dir, _ := os.MkdirTemp(os.TempDir(), "test_xdg_config")
+	os.Setenv("XDG_CONFIG_HOME", dir)
+
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
 // Make sure that the default config is set up to use color and verify TLS
 func TestNewConfig(t *testing.T) {
 	assert := assert.New(t)
@@ -19,6 +33,31 @@ func TestNewConfig(t *testing.T) {
 	assert.Equal(DefaultTimeout, New.GetTimeout())
 }
 
+func TestClientAuthPKCS12(t *testing.T) {
+	assert := assert.New(t)
+
+	p12 := ClientAuth{Cert: "/etc/certs/client.P12 "}
+	assert.True(p12.IsPKCS12())
+	assert.True(p12.UseAuth())
+
+	pfx := ClientAuth{Cert: "client.pfx"}
+	assert.True(pfx.IsPKCS12())
+
+	pem := ClientAuth{Cert: "client.pem"}
+	assert.False(pem.IsPKCS12())
+	assert.False(pem.UseAuth())
+}
+
+func TestMergeClientAuthPassphrase(t *testing.T) {
+	assert := assert.New(t)
+
+	base := &Config{ClientAuth: ClientAuth{Cert: "client.p12", Passphrase: "base"}}
+	base.Merge(&Config{ClientAuth: ClientAuth{Passphrase: " override "}})
+
+	assert.Equal("client.p12", base.ClientAuth.Cert)
+	assert.Equal(" override ", base.ClientAuth.Passphrase)
+}
+
 func TestLoadConfigurationDefault(t *testing.T) {
 	assert := assert.New(t)
 
@@ -116,7 +155,7 @@ func TestLoadConfigurationFoundTimeout(t *testing.T) {
 	os.WriteFile(testFile.Name(), []byte("url: some_url\ntimeout: 100"), 0644)
 	config, _ := LoadConfiguration(testFile.Name())
 	assert.Equal("some_url", config.URL)
-	assert.Equal(100, config.GetTimeout())
+	assert.Equal(100*time.Second, config.GetTimeout())
 }
 
 func TestLoadConfigurationInvalidTimeout(t *testing.T) {
@@ -125,9 +164,9 @@ func TestLoadConfigurationInvalidTimeout(t *testing.T) {
 	defer testFile.Close()
 
 	os.WriteFile(testFile.Name(), []byte("url: some_url\ntimeout: abc"), 0644)
-	config, _ := LoadConfiguration(testFile.Name())
-	assert.Equal("some_url", config.URL)
-	assert.Equal(DefaultTimeout, config.GetTimeout())
+	_, err := LoadConfiguration(testFile.Name())
+	assert.NotNil(err)
+	assert.Contains(err.Error(), testFile.Name()+":2: invalid timeout 'abc' for 'timeout'")
 }
 
 func TestLoadConfigurationLoadFlagsNegative(t *testing.T) {
@@ -140,14 +179,14 @@ flags:
   follow_redirects: false
   use_color: false
   verify_tls: false
-client_cert_auth:
+client_auth:
   cert:     
   key:  
   `), 0644)
 	config, _ := LoadConfiguration(testFile.Name())
-	assert.Equal(config.Flags.FollowRedirects, "false")
-	assert.Equal(config.Flags.UseColor, "false")
-	assert.Equal(config.Flags.VerifyTLS, "false")
+	assert.False(config.FollowRedirects())
+	assert.False(config.UseColor())
+	assert.False(config.VerifyTLS())
 	assert.Empty(config.ClientAuth.Cert)
 	assert.Empty(config.ClientAuth.Key)
 }
@@ -156,19 +195,410 @@ func TestLoadConfigurationLoadFlagsPositive(t *testing.T) {
 	testFile, _ := os.CreateTemp(os.TempDir(), "test_file_prefix")
 	defer testFile.Close()
 
+	certFile, _ := os.CreateTemp(os.TempDir(), "test_cert")
+	defer os.Remove(certFile.Name())
+	keyFile, _ := os.CreateTemp(os.TempDir(), "test_key")
+	defer os.Remove(keyFile.Name())
+
 	os.WriteFile(testFile.Name(), []byte(`
 flags:
   follow_redirects: true
   use_color: true
   verify_tls: true
 client_auth:
-  cert: someFile.pem
-  key: CLIENT_CERT_KEY
+  cert: `+certFile.Name()+`
+  key: `+keyFile.Name()+`
   `), 0644)
 	config, _ := LoadConfiguration(testFile.Name())
-	assert.Equal(config.Flags.FollowRedirects, "true")
-	assert.Equal(config.Flags.UseColor, "true")
-	assert.Equal(config.Flags.VerifyTLS, "true")
-	assert.Equal("someFile.pem", config.ClientAuth.Cert)
-	assert.Equal("CLIENT_CERT_KEY", config.ClientAuth.Key)
+	assert.True(config.FollowRedirects())
+	assert.True(config.UseColor())
+	assert.True(config.VerifyTLS())
+	assert.Equal(certFile.Name(), config.ClientAuth.Cert)
+	assert.Equal(keyFile.Name(), config.ClientAuth.Key)
+}
+
+func TestLoadConfigurationProfiles(t *testing.T) {
+	assert := assert.New(t)
+	testFile, _ := os.CreateTemp(os.TempDir(), "test_file_prefix")
+	defer testFile.Close()
+
+	os.WriteFile(testFile.Name(), []byte(`
+url: https://dev.ex.io
+timeout: 10
+headers:
+  X-Env: dev
+  X-Team: core
+profiles:
+  prod:
+    url: https://api.ex.io
+    display: verbose
+    headers:
+      x-env: prod
+    client_auth:
+      cert: " prod.pem "
+      key: prod.key
+    flags:
+      verify_tls: false
+`), 0644)
+	config, err := LoadConfiguration(testFile.Name())
+	assert.Nil(err)
+
+	profile, err := config.ApplyProfile("prod")
+	assert.Nil(err)
+	assert.Equal("https://api.ex.io", profile.URL)
+	assert.Equal("verbose", profile.Display)
+	assert.Equal(10*time.Second, profile.GetTimeout())
+	assert.Equal(HeaderMap{"x-env": {"prod"}, "X-Team": {"core"}}, profile.Headers)
+	// Relative files are resolved against the configuration file's directory
+	assert.Equal(filepath.Join(filepath.Dir(testFile.Name()), "prod.pem"), profile.ClientAuth.Cert)
+	assert.Equal(filepath.Join(filepath.Dir(testFile.Name()), "prod.key"), profile.ClientAuth.Key)
+	assert.False(profile.VerifyTLS())
+	assert.True(profile.FollowRedirects())
+
+	// The base configuration shouldn't be modified
+	assert.Equal("https://dev.ex.io", config.URL)
+	assert.Equal(StringList{"dev"}, config.Headers["X-Env"])
+	assert.True(config.VerifyTLS())
+}
+
+func TestApplyProfileEmpty(t *testing.T) {
+	assert := assert.New(t)
+
+	config, err := New.ApplyProfile("")
+	assert.Nil(err)
+	assert.Equal(New, config)
+}
+
+func TestApplyProfileMissing(t *testing.T) {
+	assert := assert.New(t)
+
+	_, err := New.ApplyProfile("staging")
+	assert.NotNil(err)
+	assert.Equal("could not find profile 'staging' in the configuration", err.Error())
+}
+
+func TestMergeProfiles(t *testing.T) {
+	assert := assert.New(t)
+
+	base := &Config{Profiles: map[string]*Config{"dev": {URL: "https://dev.ex.io", Timeout: "5"}}}
+	base.Merge(&Config{Profiles: map[string]*Config{"dev": {Timeout: "10"}, "prod": {URL: "https://api.ex.io"}}})
+
+	assert.Equal("https://dev.ex.io", base.Profiles["dev"].URL)
+	assert.Equal("10", base.Profiles["dev"].Timeout)
+	assert.Equal("https://api.ex.io", base.Profiles["prod"].URL)
+}
+
+func TestLoadConfigurationExtends(t *testing.T) {
+	assert := assert.New(t)
+	root := t.TempDir()
+	os.MkdirAll(filepath.Join(root, "shared"), 0755)
+	os.MkdirAll(filepath.Join(root, "project"), 0755)
+
+	os.WriteFile(filepath.Join(root, "shared", "base.yml"), []byte(`
+url: https://base.ex.io
+timeout: 10
+headers:
+  X-Team: core
+  X-Level: base
+client_auth:
+  ca: |
+    -----BEGIN CERTIFICATE-----
+    ca
+    -----END CERTIFICATE-----
+`), 0644)
+	os.WriteFile(filepath.Join(root, "shared", "cert.pem"), []byte("cert"), 0644)
+	os.WriteFile(filepath.Join(root, "shared", "key.pem"), []byte("key"), 0644)
+	os.WriteFile(filepath.Join(root, "shared", "certs.yml"), []byte(`
+extends: base.yml
+client_auth:
+  cert: `+filepath.Join(root, "shared", "cert.pem")+`
+  key: `+filepath.Join(root, "shared", "key.pem")+`
+`), 0644)
+
+	project := filepath.Join(root, "project", "gulp.yml")
+	os.WriteFile(project, []byte(`
+extends:
+  - ../shared/certs.yml
+url: https://project.ex.io
+headers:
+  X-Level: project
+`), 0644)
+
+	config, err := LoadConfiguration(project)
+	assert.Nil(err)
+	assert.Equal("https://project.ex.io", config.URL)
+	assert.Equal(10*time.Second, config.GetTimeout())
+	assert.Equal(HeaderMap{"X-Team": {"core"}, "X-Level": {"project"}}, config.Headers)
+	assert.Equal(filepath.Join(root, "shared", "cert.pem"), config.ClientAuth.Cert)
+	assert.Equal(filepath.Join(root, "shared", "key.pem"), config.ClientAuth.Key)
+	assert.Equal("-----BEGIN CERTIFICATE-----\nca\n-----END CERTIFICATE-----", config.ClientAuth.CA)
+	assert.Equal([]string{
+		filepath.Join(root, "project", "..", "shared", "base.yml"),
+		filepath.Join(root, "project", "..", "shared", "certs.yml"),
+		project,
+	}, config.Sources)
+}
+
+func TestLoadConfigurationExtendsRelativeCert(t *testing.T) {
+	assert := assert.New(t)
+
+	dir := t.TempDir()
+	shared := filepath.Join(dir, "shared")
+	project := filepath.Join(dir, "project")
+	os.MkdirAll(filepath.Join(shared, "certs"), 0755)
+	os.MkdirAll(filepath.Join(project, "sub"), 0755)
+	os.Mkdir(filepath.Join(project, ".git"), 0755)
+	os.WriteFile(filepath.Join(shared, "certs", "client.pem"), []byte("pem"), 0644)
+	os.WriteFile(filepath.Join(shared, "certs", "client.key"), []byte("pem"), 0644)
+
+	os.WriteFile(filepath.Join(shared, "gulp.yml"), []byte("client_auth:\n  cert: certs/client.pem\n  key: certs/client.key\n"), 0644)
+	os.WriteFile(filepath.Join(project, DefaultFileName), []byte("extends: ../shared/gulp.yml\nurl: https://api.ex.io\n"), 0644)
+
+	// The shared cert is found relative to the shared file, whichever directory gulp runs from
+	for _, cwd := range []string{project, filepath.Join(project, "sub")} {
+		t.Chdir(cwd)
+		config, err := LoadConfiguration(DefaultFileName)
+		assert.Nil(err)
+		assert.Equal(filepath.Join(shared, "certs", "client.pem"), config.ClientAuth.Cert)
+		assert.Equal(filepath.Join(shared, "certs", "client.key"), config.ClientAuth.Key)
+	}
+}
+
+func TestLoadConfigurationExtendsCycle(t *testing.T) {
+	assert := assert.New(t)
+	root := t.TempDir()
+
+	os.WriteFile(filepath.Join(root, "a.yml"), []byte("extends: b.yml"), 0644)
+	os.WriteFile(filepath.Join(root, "b.yml"), []byte("extends: [a.yml]"), 0644)
+
+	_, err := LoadConfiguration(filepath.Join(root, "a.yml"))
+	assert.NotNil(err)
+	assert.Equal(fmt.Sprintf("configuration include cycle detected: %s -> %s -> %s",
+		filepath.Join(root, "a.yml"), filepath.Join(root, "b.yml"), filepath.Join(root, "a.yml")), err.Error())
+}
+
+func TestLoadConfigurationExtendsParseError(t *testing.T) {
+	assert := assert.New(t)
+	root := t.TempDir()
+
+	os.WriteFile(filepath.Join(root, "a.yml"), []byte("extends: b.yml"), 0644)
+	os.WriteFile(filepath.Join(root, "b.yml"), []byte("extends: c.yml"), 0644)
+	os.WriteFile(filepath.Join(root, "c.yml"), []byte{255, 253}, 0644)
+
+	_, err := LoadConfiguration(filepath.Join(root, "a.yml"))
+	assert.NotNil(err)
+	assert.Contains(err.Error(), fmt.Sprintf("could not parse configuration file '%s' (include chain: %s -> %s -> %s)",
+		filepath.Join(root, "c.yml"), filepath.Join(root, "a.yml"), filepath.Join(root, "b.yml"), filepath.Join(root, "c.yml")))
+}
+
+func TestLoadConfigurationExtendsMissing(t *testing.T) {
+	assert := assert.New(t)
+	root := t.TempDir()
+
+	os.WriteFile(filepath.Join(root, "a.yml"), []byte("extends: missing.yml"), 0644)
+
+	_, err := LoadConfiguration(filepath.Join(root, "a.yml"))
+	assert.NotNil(err)
+	assert.Contains(err.Error(), fmt.Sprintf("could not load configuration '%s'", filepath.Join(root, "missing.yml")))
+	assert.Contains(err.Error(), "include chain")
+}
+
+func TestParseTimeout(t *testing.T) {
+	assert := assert.New(t)
+
+	d, err := ParseTimeout("30")
+	assert.Nil(err)
+	assert.Equal(30*time.Second, d)
+
+	d, err = ParseTimeout("1m30s")
+	assert.Nil(err)
+	assert.Equal(90*time.Second, d)
+
+	d, err = ParseTimeout(" 500ms ")
+	assert.Nil(err)
+	assert.Equal(500*time.Millisecond, d)
+
+	d, err = ParseTimeout("")
+	assert.Nil(err)
+	assert.Zero(d)
+
+	_, err = ParseTimeout("-5s")
+	assert.NotNil(err)
+
+	_, err = ParseTimeout("abc")
+	assert.Equal("invalid duration 'abc'", err.Error())
+}
+
+func TestLoadConfigurationPhaseTimeouts(t *testing.T) {
+	assert := assert.New(t)
+	testFile, _ := os.CreateTemp(os.TempDir(), "test_file_prefix")
+	defer testFile.Close()
+
+	os.WriteFile(testFile.Name(), []byte(`
+timeout: 1m30s
+connect_timeout: 500ms
+tls_handshake_timeout: 5
+response_header_timeout: 30s
+`), 0644)
+	config, err := LoadConfiguration(testFile.Name())
+	assert.Nil(err)
+	assert.Equal(Timeouts{
+		Overall:        90 * time.Second,
+		Connect:        500 * time.Millisecond,
+		TLSHandshake:   5 * time.Second,
+		ResponseHeader: 30 * time.Second,
+	}, config.GetTimeouts())
+	assert.Equal(Timeouts{Overall: DefaultTimeout}, New.GetTimeouts())
+}
+
+func TestLoadConfigurationCredentialHelper(t *testing.T) {
+	assert := assert.New(t)
+	testFile, _ := os.CreateTemp(os.TempDir(), "test_file_prefix")
+	defer testFile.Close()
+
+	os.WriteFile(testFile.Name(), []byte("credential_helper: ' vault-helper --role api '\n"), 0644)
+	config, err := LoadConfiguration(testFile.Name())
+	assert.Nil(err)
+	assert.Equal("vault-helper --role api", config.CredentialHelper)
+
+	config.Merge(&Config{})
+	assert.Equal("vault-helper --role api", config.CredentialHelper)
+
+	config.Merge(&Config{CredentialHelper: "op-helper"})
+	assert.Equal("op-helper", config.CredentialHelper)
+}
+
+func TestLoadConfigurationBody(t *testing.T) {
+	assert := assert.New(t)
+	testFile, _ := os.CreateTemp(os.TempDir(), "test_file_prefix")
+	defer testFile.Close()
+
+	os.WriteFile(testFile.Name(), []byte(`
+body: |
+  name: gulp
+profiles:
+  upload:
+    body: "@photo.jpg"
+`), 0644)
+	config, err := LoadConfiguration(testFile.Name())
+	assert.Nil(err)
+	assert.Equal("name: gulp\n", config.Body)
+
+	profile, err := config.ApplyProfile("upload")
+	assert.Nil(err)
+	assert.Equal("@"+filepath.Join(filepath.Dir(testFile.Name()), "photo.jpg"), profile.Body)
+}
+
+func TestLoadConfigurationRelativePaths(t *testing.T) {
+	assert := assert.New(t)
+
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "certs"), 0755)
+	os.MkdirAll(filepath.Join(dir, "sub"), 0755)
+	os.Mkdir(filepath.Join(dir, ".git"), 0755)
+	absKey := filepath.Join(t.TempDir(), "internal.key")
+	os.WriteFile(absKey, []byte("pem"), 0644)
+	for _, name := range []string{"client.pem", "client.key", "ca.pem", "jwt.pem", "internal.pem"} {
+		os.WriteFile(filepath.Join(dir, "certs", name), []byte("pem"), 0644)
+	}
+	os.WriteFile(filepath.Join(dir, DefaultFileName), []byte(`
+client_auth:
+  cert: certs/client.pem
+  key: ./certs/client.key
+  ca: " certs/ca.pem "
+jwt:
+  algorithm: RS256
+  key: certs/jwt.pem
+body: "@payloads/user.yml"
+hosts:
+  "*.internal.ex.io":
+    client_auth:
+      cert: certs/internal.pem
+      key: `+absKey+`
+`), 0644)
+
+	// The files are found when running from a subdirectory
+	t.Chdir(filepath.Join(dir, "sub"))
+	config, err := LoadConfiguration(DefaultFileName)
+	assert.Nil(err)
+	assert.Equal(filepath.Join(dir, "certs", "client.pem"), config.ClientAuth.Cert)
+	assert.Equal(filepath.Join(dir, "certs", "client.key"), config.ClientAuth.Key)
+	assert.Equal(filepath.Join(dir, "certs", "ca.pem"), config.ClientAuth.CA)
+	assert.Equal(filepath.Join(dir, "certs", "jwt.pem"), config.JWT.Key)
+	assert.Equal("@"+filepath.Join(dir, "payloads", "user.yml"), config.Body)
+	assert.Equal(filepath.Join(dir, "certs", "internal.pem"), config.Hosts["*.internal.ex.io"].ClientAuth.Cert)
+	assert.Equal(absKey, config.Hosts["*.internal.ex.io"].ClientAuth.Key)
+
+	_, problems := Validate(DefaultFileName)
+	assert.Empty(problems)
+}
+
+func TestResolvePath(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal(filepath.Join("conf", "c.pem"), resolvePath("conf", " c.pem "))
+	assert.Equal("/etc/c.pem", resolvePath("conf", "/etc/c.pem"))
+	assert.Equal("-----BEGIN CERTIFICATE-----", resolvePath("conf", "-----BEGIN CERTIFICATE-----"))
+	assert.Equal("${CERT}", resolvePath("conf", "${CERT}"))
+	assert.Equal("$(file:cert.path)", resolvePath("conf", "$(file:cert.path)"))
+	assert.Empty(resolvePath("conf", ""))
+}
+
+func TestLoadConfigurationQuery(t *testing.T) {
+	assert := assert.New(t)
+	testFile, _ := os.CreateTemp(os.TempDir(), "test_file_prefix")
+	defer testFile.Close()
+
+	os.WriteFile(testFile.Name(), []byte(`
+query:
+  version: 2
+  tag: [a, b]
+profiles:
+  beta:
+    query:
+      version: beta
+`), 0644)
+	config, err := LoadConfiguration(testFile.Name())
+	assert.Nil(err)
+	assert.Equal(QueryMap{"version": {"2"}, "tag": {"a", "b"}}, config.Query)
+	assert.Equal(url.Values{"version": {"2"}, "tag": {"a", "b"}}, config.Query.Values())
+
+	profile, err := config.ApplyProfile("beta")
+	assert.Nil(err)
+	assert.Equal(QueryMap{"version": {"beta"}, "tag": {"a", "b"}}, profile.Query)
+	assert.Equal(StringList{"2"}, config.Query["version"])
+}
+
+func TestLoadConfigurationHeaderLists(t *testing.T) {
+	assert := assert.New(t)
+	testFile, _ := os.CreateTemp(os.TempDir(), "test_file_prefix")
+	defer testFile.Close()
+
+	os.WriteFile(testFile.Name(), []byte(`
+headers:
+  X-Version: 2
+  Accept:
+    - application/json
+    - text/plain
+`), 0644)
+	config, err := LoadConfiguration(testFile.Name())
+	assert.Nil(err)
+	assert.Equal(HeaderMap{"X-Version": {"2"}, "Accept": {"application/json", "text/plain"}}, config.Headers)
+	assert.Empty(validateData(testFile.Name(), []byte("headers:\n  X-Version: 2\n  Accept: [application/json, text/plain]\n")))
+
+	// A list replaces all of the values of a header
+	config.Merge(&Config{Headers: HeaderMap{"accept": {"text/html"}}})
+	assert.Equal(HeaderMap{"X-Version": {"2"}, "accept": {"text/html"}}, config.Headers)
+}
+
+func TestStringListJSON(t *testing.T) {
+	assert := assert.New(t)
+
+	dat, _ := json.Marshal(HeaderMap{"Accept": {"application/json", "text/plain"}, "X-Team": {"core"}})
+	assert.Equal(`{"Accept":["application/json","text/plain"],"X-Team":"core"}`, string(dat))
+
+	var list StringList
+	assert.Nil(json.Unmarshal([]byte(`[1, true, "a"]`), &list))
+	assert.Equal(StringList{"1", "true", "a"}, list)
+	assert.Equal("expected a string or a list of strings", json.Unmarshal([]byte(`{"a": "b"}`), &list).Error())
 }
@@ -0,0 +1,147 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+)
+
+// DefaultFileName is the name of the project configuration file
+const DefaultFileName = ".gulp.yml"
+
+// GlobalConfigPath returns the location of the user-level configuration file:
+// $XDG_CONFIG_HOME/gulp/config.yml, falling back to ~/.config/gulp/config.yml
+func GlobalConfigPath() string {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		dir = filepath.Join(home, ".config")
+	}
+
+	return filepath.Join(dir, "gulp", "config.yml")
+}
+
+// CacheDir returns the directory used for cached data like OAuth2 tokens:
+// $XDG_CACHE_HOME/gulp, falling back to the OS cache directory (ie. ~/.cache/gulp)
+func CacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+
+	return filepath.Join(dir, "gulp")
+}
+
+// sessionName limits session names to what's safe to use as a directory name
+var sessionName = regexp.MustCompile(`^[A-Za-z0-9_-][A-Za-z0-9_.-]*$`)
+
+// SessionDir returns the directory that keeps the cookies and headers of a -session:
+// $XDG_CONFIG_HOME/gulp/sessions/<name>, falling back to ~/.config/gulp/sessions/<name>
+func SessionDir(name string) (string, error) {
+	if !sessionName.MatchString(name) {
+		return "", fmt.Errorf("invalid session name '%s', expected letters, numbers, '.', '-' or '_'", name)
+	}
+
+	global := GlobalConfigPath()
+	if global == "" {
+		return "", fmt.Errorf("could not find the home directory to keep the session in")
+	}
+
+	return filepath.Join(filepath.Dir(global), "sessions", name), nil
+}
+
+// FindProjectConfig returns the nearest .gulp.yml (or "" if none exists), walking up from dir to the repository root
+// (the directory with .git) or the home directory. Outside of both, only dir is checked, so that a file in a shared
+// parent directory like /tmp, which could run commands with $(cmd:...) or a credential_helper, isn't picked up.
+func FindProjectConfig(dir string) string {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return ""
+	}
+
+	root := projectRoot(dir)
+	for {
+		candidate := filepath.Join(dir, DefaultFileName)
+		if isFile(candidate) {
+			return candidate
+		}
+
+		parent := filepath.Dir(dir)
+		if root == "" || dir == root || parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// projectRoot returns the nearest directory from dir up that is a repository root or the home directory,
+// or "" if dir is outside of both
+func projectRoot(dir string) string {
+	home, err := os.UserHomeDir()
+	if err == nil {
+		home = filepath.Clean(home)
+	}
+
+	for {
+		if dir == home {
+			return dir
+		}
+
+		// .git is a file in worktrees and submodules
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return dir
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// discoverFiles returns the configuration files to merge, from lowest to highest precedence
+func discoverFiles(fileName string) []string {
+	var files []string
+	if global := GlobalConfigPath(); global != "" && isFile(global) {
+		files = append(files, global)
+	}
+
+	if cwd, err := os.Getwd(); err == nil {
+		if project := FindProjectConfig(cwd); project != "" && !samePath(project, files) {
+			files = append(files, project)
+		}
+	}
+
+	// The default file name is handled by the project discovery above
+	if fileName != DefaultFileName && !samePath(fileName, files) {
+		files = append(files, fileName)
+	}
+
+	return files
+}
+
+// samePath checks whether the file was already found (i.e. -c points to the project or global file)
+func samePath(fileName string, files []string) bool {
+	abs, err := filepath.Abs(fileName)
+	if err != nil {
+		return false
+	}
+
+	for _, f := range files {
+		if existing, err := filepath.Abs(f); err == nil && existing == abs {
+			return true
+		}
+	}
+
+	return false
+}
+
+func isFile(fileName string) bool {
+	info, err := os.Stat(fileName)
+	return err == nil && !info.IsDir()
+}
@@ -0,0 +1,164 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGlobalConfigPath(t *testing.T) {
+	assert := assert.New(t)
+	t.Setenv("XDG_CONFIG_HOME", "/tmp/xdg")
+
+	assert.Equal(filepath.Join("/tmp/xdg", "gulp", "config.yml"), GlobalConfigPath())
+}
+
+func TestCacheDir(t *testing.T) {
+	assert := assert.New(t)
+	t.Setenv("XDG_CACHE_HOME", "/tmp/xdg-cache")
+
+	assert.Equal(filepath.Join("/tmp/xdg-cache", "gulp"), CacheDir())
+}
+
+func TestSessionDir(t *testing.T) {
+	assert := assert.New(t)
+	t.Setenv("XDG_CONFIG_HOME", "/tmp/xdg")
+
+	dir, err := SessionDir("login_v2.prod")
+	assert.Nil(err)
+	assert.Equal(filepath.Join("/tmp/xdg", "gulp", "sessions", "login_v2.prod"), dir)
+
+	for _, name := range []string{"", "..", "../other", "a/b", "with space"} {
+		_, err := SessionDir(name)
+		assert.EqualError(err, "invalid session name '"+name+"', expected letters, numbers, '.', '-' or '_'")
+	}
+}
+
+func TestGlobalConfigPathHome(t *testing.T) {
+	assert := assert.New(t)
+	t.Setenv("XDG_CONFIG_HOME", "")
+	t.Setenv("HOME", "/home/gulp")
+
+	assert.Equal(filepath.Join("/home/gulp", ".config", "gulp", "config.yml"), GlobalConfigPath())
+}
+
+func TestFindProjectConfig(t *testing.T) {
+	assert := assert.New(t)
+	root := t.TempDir()
+	nested := filepath.Join(root, "some", "sub", "dir")
+	os.MkdirAll(nested, 0755)
+	os.Mkdir(filepath.Join(root, ".git"), 0755)
+	os.WriteFile(filepath.Join(root, DefaultFileName), []byte("url: https://api.ex.io"), 0644)
+
+	assert.Equal(filepath.Join(root, DefaultFileName), FindProjectConfig(nested))
+	assert.Equal(filepath.Join(root, DefaultFileName), FindProjectConfig(root))
+}
+
+func TestFindProjectConfigMissing(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Empty(FindProjectConfig(t.TempDir()))
+}
+
+func TestFindProjectConfigStopsAtRepository(t *testing.T) {
+	assert := assert.New(t)
+	parent := t.TempDir()
+	repo := filepath.Join(parent, "repo")
+	nested := filepath.Join(repo, "sub")
+	os.MkdirAll(nested, 0755)
+	os.WriteFile(filepath.Join(repo, ".git"), []byte("gitdir: ../.git/worktrees/repo"), 0644)
+	os.WriteFile(filepath.Join(parent, DefaultFileName), []byte("url: https://api.ex.io"), 0644)
+
+	assert.Empty(FindProjectConfig(nested))
+}
+
+func TestFindProjectConfigStopsAtHome(t *testing.T) {
+	assert := assert.New(t)
+	parent := t.TempDir()
+	home := filepath.Join(parent, "home")
+	nested := filepath.Join(home, "projects", "api")
+	os.MkdirAll(nested, 0755)
+	os.WriteFile(filepath.Join(parent, DefaultFileName), []byte("url: https://api.ex.io"), 0644)
+	t.Setenv("HOME", home)
+
+	assert.Empty(FindProjectConfig(nested))
+
+	os.WriteFile(filepath.Join(home, DefaultFileName), []byte("url: https://api.ex.io"), 0644)
+	assert.Equal(filepath.Join(home, DefaultFileName), FindProjectConfig(nested))
+}
+
+func TestFindProjectConfigOutsideProject(t *testing.T) {
+	assert := assert.New(t)
+
+	// A file in a shared parent directory like /tmp isn't used
+	parent := t.TempDir()
+	nested := filepath.Join(parent, "shared")
+	os.MkdirAll(nested, 0755)
+	os.WriteFile(filepath.Join(parent, DefaultFileName), []byte("url: https://api.ex.io"), 0644)
+
+	assert.Empty(FindProjectConfig(nested))
+
+	os.WriteFile(filepath.Join(nested, DefaultFileName), []byte("url: https://api.ex.io"), 0644)
+	assert.Equal(filepath.Join(nested, DefaultFileName), FindProjectConfig(nested))
+}
+
+func TestLoadConfigurationHierarchy(t *testing.T) {
+	assert := assert.New(t)
+	xdg := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", xdg)
+	global := filepath.Join(xdg, "gulp", "config.yml")
+	os.MkdirAll(filepath.Dir(global), 0755)
+	os.WriteFile(global, []byte(`
+url: https://global.ex.io
+timeout: 10
+headers:
+  X-Global: global
+  X-Level: global
+flags:
+  use_color: false
+`), 0644)
+
+	root := t.TempDir()
+	os.Mkdir(filepath.Join(root, ".git"), 0755)
+	project := filepath.Join(root, DefaultFileName)
+	os.WriteFile(project, []byte(`
+url: https://project.ex.io
+headers:
+  X-Level: project
+`), 0644)
+
+	explicit := filepath.Join(root, "explicit.yml")
+	os.WriteFile(explicit, []byte(`
+headers:
+  x-level: explicit
+display: verbose
+`), 0644)
+
+	nested := filepath.Join(root, "sub")
+	os.MkdirAll(nested, 0755)
+	t.Chdir(nested)
+
+	config, err := LoadConfiguration(DefaultFileName)
+	assert.Nil(err)
+	assert.Equal([]string{global, project}, config.Sources)
+	assert.Equal("https://project.ex.io", config.URL)
+	assert.Equal(10*time.Second, config.GetTimeout())
+	assert.False(config.UseColor())
+	assert.Equal(StringList{"project"}, config.Headers["X-Level"])
+	assert.Equal(StringList{"global"}, config.Headers["X-Global"])
+
+	config, err = LoadConfiguration(explicit)
+	assert.Nil(err)
+	assert.Equal([]string{global, project, explicit}, config.Sources)
+	assert.Equal("https://project.ex.io", config.URL)
+	assert.Equal("verbose", config.Display)
+	assert.Equal(HeaderMap{"X-Global": {"global"}, "x-level": {"explicit"}}, config.Headers)
+
+	// Pointing -c at the project file shouldn't load it twice
+	config, err = LoadConfiguration(project)
+	assert.Nil(err)
+	assert.Equal([]string{global, project}, config.Sources)
+}
@@ -0,0 +1,89 @@
+package config
+
+import (
+	"net"
+	"path"
+	"sort"
+	"strings"
+)
+
+// Host contains the settings applied to requests for hosts matching the block's hostname or glob (ie. *.internal.example.com)
+type Host struct {
+	Headers    HeaderMap   `json:"headers" description:"Headers sent with requests to the host"`
+	ClientAuth ClientAuth  `json:"client_auth" description:"The client certificate and key used for the host, and a custom CA"`
+	Flags      ConfigFlags `json:"flags" description:"Options applied to requests to the host"`
+	Timeout    string      `json:"timeout" validate:"timeout" description:"How long to wait for requests to the host"`
+}
+
+// asConfig converts the host block so that it can be merged on top of a configuration
+func (h *Host) asConfig() *Config {
+	return &Config{Headers: h.Headers, ClientAuth: h.ClientAuth, Flags: h.Flags, Timeout: h.Timeout}
+}
+
+// merge overlays the values set in override on top of the host block
+func (h *Host) merge(override *Host) {
+	merged := h.asConfig()
+	merged.Merge(override.asConfig())
+
+	h.Headers = merged.Headers
+	h.ClientAuth = merged.ClientAuth
+	h.Flags = merged.Flags
+	h.Timeout = merged.Timeout
+}
+
+// ForHost returns a copy of the configuration with every matching host block merged on top of it.
+// The host can include a port; patterns are compared against both "host" and "host:port".
+func (gc *Config) ForHost(host string) *Config {
+	matches := gc.MatchingHosts(host)
+	if len(matches) == 0 {
+		return gc
+	}
+
+	merged := &Config{Sources: gc.Sources}
+	merged.Merge(gc)
+	for _, h := range matches {
+		merged.Merge(h.asConfig())
+	}
+
+	return merged
+}
+
+// MatchingHosts returns the host blocks that match the host, from least to most specific:
+// wildcard patterns come before exact hostnames, and shorter patterns before longer ones.
+func (gc *Config) MatchingHosts(host string) []*Host {
+	host = strings.ToLower(host)
+	hostname := host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		hostname = h
+	}
+
+	var patterns []string
+	for pattern, h := range gc.Hosts {
+		if h != nil && (matchHost(pattern, hostname) || matchHost(pattern, host)) {
+			patterns = append(patterns, pattern)
+		}
+	}
+
+	sort.Slice(patterns, func(i, j int) bool {
+		wi, wj := strings.ContainsAny(patterns[i], "*?["), strings.ContainsAny(patterns[j], "*?[")
+		if wi != wj {
+			return wi
+		}
+		if len(patterns[i]) != len(patterns[j]) {
+			return len(patterns[i]) < len(patterns[j])
+		}
+		return patterns[i] < patterns[j]
+	})
+
+	matches := make([]*Host, len(patterns))
+	for i, pattern := range patterns {
+		matches[i] = gc.Hosts[pattern]
+	}
+
+	return matches
+}
+
+func matchHost(pattern, host string) bool {
+	ok, err := path.Match(strings.ToLower(pattern), host)
+	return err == nil && ok
+}
@@ -0,0 +1,119 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func hostsConfig() *Config {
+	return &Config{
+		URL:     "https://api.ex.io",
+		Headers: HeaderMap{"X-Base": {"base"}, "X-Env": {"default"}},
+		Timeout: "30",
+		Hosts: map[string]*Host{
+			"*.internal.ex.io": {
+				Headers:    HeaderMap{"X-Env": {"internal"}},
+				ClientAuth: ClientAuth{Cert: "internal.pem", Key: "internal.key"},
+				Flags:      ConfigFlags{VerifyTLS: NewBool(false)},
+			},
+			"billing.internal.ex.io": {
+				Headers: HeaderMap{"x-env": {"billing"}},
+				Timeout: "5s",
+			},
+			"billing.internal.ex.io:8443": {
+				ClientAuth: ClientAuth{Cert: "billing.pem"},
+			},
+		},
+	}
+}
+
+func TestForHostNoMatch(t *testing.T) {
+	assert := assert.New(t)
+
+	gc := hostsConfig()
+	assert.Same(gc, gc.ForHost("api.ex.io"))
+	assert.Empty(gc.MatchingHosts("internal.ex.io"))
+}
+
+func TestForHostWildcard(t *testing.T) {
+	assert := assert.New(t)
+
+	gc := hostsConfig()
+	c := gc.ForHost("Users.Internal.ex.io")
+	assert.Equal(HeaderMap{"X-Base": {"base"}, "X-Env": {"internal"}}, c.Headers)
+	assert.Equal("internal.pem", c.ClientAuth.Cert)
+	assert.False(c.VerifyTLS())
+	assert.Equal("30", c.Timeout)
+
+	// The original configuration isn't changed
+	assert.Equal(StringList{"default"}, gc.Headers["X-Env"])
+	assert.Equal("", gc.ClientAuth.Cert)
+}
+
+func TestForHostMostSpecificWins(t *testing.T) {
+	assert := assert.New(t)
+
+	c := hostsConfig().ForHost("billing.internal.ex.io:8443")
+	assert.Equal(HeaderMap{"X-Base": {"base"}, "x-env": {"billing"}}, c.Headers)
+	assert.Equal("billing.pem", c.ClientAuth.Cert)
+	assert.Equal("internal.key", c.ClientAuth.Key)
+	assert.Equal("5s", c.Timeout)
+}
+
+func TestMatchingHostsOrder(t *testing.T) {
+	assert := assert.New(t)
+
+	gc := hostsConfig()
+	matches := gc.MatchingHosts("billing.internal.ex.io:8443")
+	assert.Equal([]*Host{
+		gc.Hosts["*.internal.ex.io"],
+		gc.Hosts["billing.internal.ex.io"],
+		gc.Hosts["billing.internal.ex.io:8443"],
+	}, matches)
+
+	// The port specific block only matches when the port is used
+	assert.Len(gc.MatchingHosts("billing.internal.ex.io"), 2)
+}
+
+func TestMergeHosts(t *testing.T) {
+	assert := assert.New(t)
+
+	gc := hostsConfig()
+	gc.Merge(&Config{Hosts: map[string]*Host{
+		"*.internal.ex.io": {Headers: HeaderMap{"X-Team": {"core"}}, Timeout: "10"},
+		"*.ex.com":         {Timeout: "1m"},
+	}})
+
+	internal := gc.Hosts["*.internal.ex.io"]
+	assert.Equal(HeaderMap{"X-Env": {"internal"}, "X-Team": {"core"}}, internal.Headers)
+	assert.Equal("internal.pem", internal.ClientAuth.Cert)
+	assert.Equal(NewBool(false), internal.Flags.VerifyTLS)
+	assert.Equal("10", internal.Timeout)
+	assert.Equal("1m", gc.Hosts["*.ex.com"].Timeout)
+	assert.Len(gc.Hosts, 4)
+}
+
+func TestValidateDataHosts(t *testing.T) {
+	assert := assert.New(t)
+
+	problems := validateData("test.yml", []byte(`hosts:
+  "*.internal.ex.io":
+    header:
+      X-Env: internal
+    timeout: soon
+    flags:
+      verify_tls: nope
+`))
+
+	var messages []string
+	for _, p := range problems {
+		messages = append(messages, p.String())
+	}
+
+	assert.Equal([]string{
+		"test.yml:3: unknown key 'header' in 'hosts.*.internal.ex.io', did you mean 'headers'?",
+		"test.yml:5: invalid timeout 'soon' for 'hosts.*.internal.ex.io.timeout', expected a duration (ie. 500ms, 1m30s) or a number of seconds",
+		"test.yml:7: invalid boolean 'nope' for 'hosts.*.internal.ex.io.flags.verify_tls', expected true or false",
+	}, messages)
+}
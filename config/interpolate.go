@@ -0,0 +1,208 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// commandOutput caches the output of $(cmd:...) references so that each command only runs once,
+// even though a value is resolved both when it is validated and when it is loaded
+var commandOutput sync.Map
+
+// expandReferences resolves the ${VAR}, ${VAR:-default}, $(file:/path) and $(cmd:...) references
+// in every string value of the configuration.
+// Profiles are skipped since they are only resolved when selected (see ApplyProfile).
+func (gc *Config) expandReferences() error {
+	profiles := gc.Profiles
+	gc.Profiles = nil
+	defer func() { gc.Profiles = profiles }()
+
+	return interpolateValue(reflect.ValueOf(gc))
+}
+
+// interpolator is implemented by values that keep their references in unexported fields (see Bool)
+type interpolator interface {
+	interpolate() error
+}
+
+// interpolateValue walks the value and expands the references found in any string it contains
+func interpolateValue(v reflect.Value) error {
+	if v.CanAddr() {
+		if i, ok := v.Addr().Interface().(interpolator); ok {
+			return i.interpolate()
+		}
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		expanded, err := Interpolate(v.String())
+		if err != nil {
+			return err
+		}
+		v.SetString(expanded)
+	case reflect.Ptr, reflect.Interface:
+		if !v.IsNil() {
+			return interpolateValue(v.Elem())
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).IsExported() {
+				if err := interpolateValue(v.Field(i)); err != nil {
+					return err
+				}
+			}
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			if err := interpolateValue(v.Index(i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		// Map values aren't addressable, so expand a copy and put it back
+		for _, key := range v.MapKeys() {
+			elem := reflect.New(v.Type().Elem()).Elem()
+			elem.Set(v.MapIndex(key))
+			if err := interpolateValue(elem); err != nil {
+				return err
+			}
+			v.SetMapIndex(key, elem)
+		}
+	}
+
+	return nil
+}
+
+// Interpolate expands the variable and source references in a single value.
+// A literal "${" or "$(" can be written by doubling the dollar sign ("$${", "$$(").
+func Interpolate(value string) (string, error) {
+	if !strings.Contains(value, "$") {
+		return value, nil
+	}
+
+	var sb strings.Builder
+	for i := 0; i < len(value); i++ {
+		if value[i] != '$' || i+1 == len(value) {
+			sb.WriteByte(value[i])
+			continue
+		}
+
+		switch value[i+1] {
+		case '$':
+			if i+2 < len(value) && (value[i+2] == '{' || value[i+2] == '(') {
+				sb.WriteString("$" + value[i+2:i+3])
+				i += 2
+				continue
+			}
+			sb.WriteByte('$')
+		case '{':
+			end := strings.IndexByte(value[i+2:], '}')
+			if end < 0 {
+				return "", fmt.Errorf("unterminated variable reference in '%s'", value)
+			}
+
+			resolved, err := resolveVariable(value[i+2 : i+2+end])
+			if err != nil {
+				return "", err
+			}
+			sb.WriteString(resolved)
+			i += 2 + end
+		case '(':
+			end := closingParen(value[i+2:])
+			if end < 0 {
+				return "", fmt.Errorf("unterminated source reference in '%s'", value)
+			}
+
+			resolved, err := resolveSource(value[i+2 : i+2+end])
+			if err != nil {
+				return "", err
+			}
+			sb.WriteString(resolved)
+			i += 2 + end
+		default:
+			sb.WriteByte('$')
+		}
+	}
+
+	return sb.String(), nil
+}
+
+// resolveVariable looks up an environment variable, using the default if one was given ("VAR:-default")
+func resolveVariable(reference string) (string, error) {
+	name, def, hasDefault := strings.Cut(reference, ":-")
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", fmt.Errorf("empty variable reference '${%s}'", reference)
+	}
+
+	value, ok := os.LookupEnv(name)
+	if hasDefault && value == "" {
+		return def, nil
+	}
+
+	if !ok {
+		return "", fmt.Errorf("unresolved variable '%s': it is not set in the environment", name)
+	}
+
+	return value, nil
+}
+
+// resolveSource reads the value from a file ("file:/path") or the output of a command ("cmd:...")
+func resolveSource(reference string) (string, error) {
+	kind, arg, _ := strings.Cut(reference, ":")
+	arg = strings.TrimSpace(arg)
+
+	switch kind {
+	case "file":
+		dat, err := os.ReadFile(arg)
+		if err != nil {
+			return "", fmt.Errorf("unresolved file reference '$(%s)': %v", reference, err)
+		}
+
+		return strings.TrimSpace(string(dat)), nil
+	case "cmd":
+		if out, ok := commandOutput.Load(arg); ok {
+			return out.(string), nil
+		}
+
+		var stderr bytes.Buffer
+		cmd := exec.Command("sh", "-c", arg)
+		cmd.Stderr = &stderr
+		out, err := cmd.Output()
+		if err != nil {
+			if msg := strings.TrimSpace(stderr.String()); msg != "" {
+				err = fmt.Errorf("%v: %s", err, msg)
+			}
+			return "", fmt.Errorf("unresolved command reference '$(%s)': %v", reference, err)
+		}
+
+		value := strings.TrimSpace(string(out))
+		commandOutput.Store(arg, value)
+		return value, nil
+	}
+
+	return "", fmt.Errorf("unknown source '%s' in reference '$(%s)', expected 'file' or 'cmd'", kind, reference)
+}
+
+// closingParen returns the index of the parenthesis that closes the reference, accounting for nesting
+func closingParen(value string) int {
+	depth := 0
+	for i, c := range value {
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			if depth == 0 {
+				return i
+			}
+			depth--
+		}
+	}
+
+	return -1
+}
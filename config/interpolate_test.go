@@ -0,0 +1,151 @@
+package config
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInterpolateNoReferences(t *testing.T) {
+	assert := assert.New(t)
+
+	value, err := Interpolate("pa$word")
+	assert.Nil(err)
+	assert.Equal("pa$word", value)
+}
+
+func TestInterpolateVariable(t *testing.T) {
+	assert := assert.New(t)
+	t.Setenv("GULP_TEST_TOKEN", "abc123def")
+
+	value, err := Interpolate("Bearer ${GULP_TEST_TOKEN}")
+	assert.Nil(err)
+	assert.Equal("Bearer abc123def", value)
+}
+
+func TestInterpolateVariableDefault(t *testing.T) {
+	assert := assert.New(t)
+	t.Setenv("GULP_TEST_EMPTY", "")
+
+	value, err := Interpolate("${GULP_TEST_MISSING:-30}/${GULP_TEST_EMPTY:-fallback}")
+	assert.Nil(err)
+	assert.Equal("30/fallback", value)
+}
+
+func TestInterpolateVariableMissing(t *testing.T) {
+	assert := assert.New(t)
+
+	_, err := Interpolate("${GULP_TEST_MISSING}")
+	assert.NotNil(err)
+	assert.Contains(err.Error(), "unresolved variable 'GULP_TEST_MISSING'")
+}
+
+func TestInterpolateUnterminated(t *testing.T) {
+	assert := assert.New(t)
+
+	_, err := Interpolate("${GULP_TEST_TOKEN")
+	assert.NotNil(err)
+	assert.Contains(err.Error(), "unterminated variable reference")
+
+	_, err = Interpolate("$(cmd:echo hi")
+	assert.NotNil(err)
+	assert.Contains(err.Error(), "unterminated source reference")
+}
+
+func TestInterpolateEscaped(t *testing.T) {
+	assert := assert.New(t)
+
+	value, err := Interpolate("$${NOT_A_VAR} $$(not a cmd)")
+	assert.Nil(err)
+	assert.Equal("${NOT_A_VAR} $(not a cmd)", value)
+}
+
+func TestInterpolateFile(t *testing.T) {
+	assert := assert.New(t)
+	testFile, _ := os.CreateTemp(os.TempDir(), "test_secret")
+	defer os.Remove(testFile.Name())
+	os.WriteFile(testFile.Name(), []byte("s3cr3t\n"), 0600)
+
+	value, err := Interpolate("Bearer $(file:" + testFile.Name() + ")")
+	assert.Nil(err)
+	assert.Equal("Bearer s3cr3t", value)
+
+	_, err = Interpolate("$(file:/nonexistent/secret)")
+	assert.NotNil(err)
+	assert.Contains(err.Error(), "unresolved file reference '$(file:/nonexistent/secret)'")
+}
+
+func TestInterpolateCommand(t *testing.T) {
+	assert := assert.New(t)
+
+	value, err := Interpolate("$(cmd:echo $(echo abc123))")
+	assert.Nil(err)
+	assert.Equal("abc123", value)
+
+	_, err = Interpolate("$(cmd:echo oops >&2; exit 3)")
+	assert.NotNil(err)
+	assert.Contains(err.Error(), "unresolved command reference")
+	assert.Contains(err.Error(), "oops")
+}
+
+func TestInterpolateUnknownSource(t *testing.T) {
+	assert := assert.New(t)
+
+	_, err := Interpolate("$(vault:secret/token)")
+	assert.NotNil(err)
+	assert.Contains(err.Error(), "unknown source 'vault'")
+}
+
+func TestLoadConfigurationInterpolation(t *testing.T) {
+	assert := assert.New(t)
+	t.Setenv("GULP_TEST_HOST", "api.ex.io")
+	t.Setenv("GULP_TEST_TOKEN", "abc123def")
+	certDir := t.TempDir()
+	t.Setenv("GULP_TEST_CERT_DIR", certDir)
+	os.WriteFile(certDir+"/cert.pem", []byte("cert"), 0644)
+	testFile, _ := os.CreateTemp(os.TempDir(), "test_file_prefix")
+	defer testFile.Close()
+
+	os.WriteFile(testFile.Name(), []byte(`
+url: https://${GULP_TEST_HOST}
+timeout: ${GULP_TEST_TIMEOUT:-45}
+headers:
+  Authorization: Bearer ${GULP_TEST_TOKEN}
+client_auth:
+  cert: ${GULP_TEST_CERT_DIR:-/etc/certs}/cert.pem
+profiles:
+  prod:
+    url: https://${GULP_TEST_PROD_HOST}
+`), 0644)
+	config, err := LoadConfiguration(testFile.Name())
+	assert.Nil(err)
+	assert.Equal("https://api.ex.io", config.URL)
+	assert.Equal(45*time.Second, config.GetTimeout())
+	assert.Equal(StringList{"Bearer abc123def"}, config.Headers["Authorization"])
+	assert.Equal(certDir+"/cert.pem", config.ClientAuth.Cert)
+
+	// Profiles are only resolved when they're applied
+	_, err = config.ApplyProfile("prod")
+	assert.NotNil(err)
+	assert.Contains(err.Error(), "could not resolve profile 'prod'")
+	assert.Contains(err.Error(), "GULP_TEST_PROD_HOST")
+
+	t.Setenv("GULP_TEST_PROD_HOST", "prod.ex.io")
+	profile, err := config.ApplyProfile("prod")
+	assert.Nil(err)
+	assert.Equal("https://prod.ex.io", profile.URL)
+}
+
+func TestLoadConfigurationInterpolationMissing(t *testing.T) {
+	assert := assert.New(t)
+	testFile, _ := os.CreateTemp(os.TempDir(), "test_file_prefix")
+	defer testFile.Close()
+
+	os.WriteFile(testFile.Name(), []byte("headers:\n  Authorization: Bearer ${GULP_TEST_MISSING_TOKEN}"), 0644)
+	_, err := LoadConfiguration(testFile.Name())
+	assert.NotNil(err)
+	assert.Contains(err.Error(), testFile.Name())
+	assert.Contains(err.Error(), "unresolved variable 'GULP_TEST_MISSING_TOKEN'")
+}
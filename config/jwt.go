@@ -0,0 +1,75 @@
+package config
+
+import "strings"
+
+// JWT contains the settings used to sign a short-lived token for each request
+type JWT struct {
+	Algorithm string `json:"algorithm" validate:"enum=RS256|ES256|HS256" description:"The signing algorithm"`
+	Key       string `json:"key,omitempty" validate:"file" description:"The PEM private key file or inline PEM content used with RS256 and ES256"`
+	Secret    string `json:"secret,omitempty" description:"The shared secret used with HS256, usually a ${VAR} or $(file:path) reference"`
+	KeyID     string `json:"key_id,omitempty" description:"The kid header, used by the verifier to pick the key"`
+	Claims    string `json:"claims,omitempty" description:"The Go template of the JSON claims, ie. {\"iss\": \"gulp\", \"iat\": {{iat}}, \"exp\": {{exp}}, \"jti\": \"{{jti}}\"}"`
+	ExpiresIn string `json:"expires_in,omitempty" validate:"timeout" description:"How long each token is valid for, used by {{exp}} (default 5m)"`
+	Header    string `json:"header,omitempty" description:"The header the token is sent in (default Authorization, as a Bearer token)"`
+}
+
+// HeaderName returns the header the token is sent in
+func (j *JWT) HeaderName() string {
+	if j.Header == "" {
+		return "Authorization"
+	}
+
+	return j.Header
+}
+
+// merge overlays the settings set in override
+func (j *JWT) merge(override *JWT) {
+	if override.Algorithm != "" {
+		j.Algorithm = override.Algorithm
+	}
+
+	if override.Key != "" {
+		j.Key = override.Key
+	}
+
+	if override.Secret != "" {
+		j.Secret = override.Secret
+	}
+
+	if override.KeyID != "" {
+		j.KeyID = override.KeyID
+	}
+
+	if override.Claims != "" {
+		j.Claims = override.Claims
+	}
+
+	if override.ExpiresIn != "" {
+		j.ExpiresIn = override.ExpiresIn
+	}
+
+	if override.Header != "" {
+		j.Header = override.Header
+	}
+}
+
+// redacted masks the secret and an inline key
+func (j JWT) redacted() *JWT {
+	j.Secret = maskValue(j.Secret)
+	if strings.HasPrefix(j.Key, "-----BEGIN") {
+		j.Key = secretMask
+	}
+
+	return &j
+}
+
+// trimSpace cleans up spaced padding around the values
+func (j *JWT) trimSpace() {
+	j.Algorithm = strings.TrimSpace(j.Algorithm)
+	j.Key = strings.TrimSpace(j.Key)
+	j.Secret = strings.TrimSpace(j.Secret)
+	j.KeyID = strings.TrimSpace(j.KeyID)
+	j.Claims = strings.TrimSpace(j.Claims)
+	j.ExpiresIn = strings.TrimSpace(j.ExpiresIn)
+	j.Header = strings.TrimSpace(j.Header)
+}
@@ -0,0 +1,76 @@
+package config
+
+import "strings"
+
+// OAuth2 contains the settings used to fetch an access token that is sent as a bearer token
+type OAuth2 struct {
+	TokenURL     string     `json:"token_url" description:"The token endpoint, ie. https://auth.ex.io/oauth/token"`
+	ClientID     string     `json:"client_id" description:"The client ID"`
+	ClientSecret string     `json:"client_secret,omitempty" description:"The client secret"`
+	Scopes       StringList `json:"scopes,omitempty" description:"The scopes to request"`
+	Audience     string     `json:"audience,omitempty" description:"The audience (API identifier) to request the token for"`
+	GrantType    string     `json:"grant_type,omitempty" validate:"enum=client_credentials|refresh_token" description:"The grant used to fetch the token (default client_credentials)"`
+	RefreshToken string     `json:"refresh_token,omitempty" description:"The refresh token used with the refresh_token grant"`
+}
+
+// OAuth2 grant types
+const (
+	GrantClientCredentials = "client_credentials"
+	GrantRefreshToken      = "refresh_token"
+)
+
+// Grant returns the grant type, defaulting to client_credentials
+func (o *OAuth2) Grant() string {
+	if o.GrantType == "" {
+		return GrantClientCredentials
+	}
+
+	return o.GrantType
+}
+
+// merge overlays the settings set in override
+func (o *OAuth2) merge(override *OAuth2) {
+	if override.TokenURL != "" {
+		o.TokenURL = override.TokenURL
+	}
+
+	if override.ClientID != "" {
+		o.ClientID = override.ClientID
+	}
+
+	if override.ClientSecret != "" {
+		o.ClientSecret = override.ClientSecret
+	}
+
+	if len(override.Scopes) > 0 {
+		o.Scopes = append(StringList(nil), override.Scopes...)
+	}
+
+	if override.Audience != "" {
+		o.Audience = override.Audience
+	}
+
+	if override.GrantType != "" {
+		o.GrantType = override.GrantType
+	}
+
+	if override.RefreshToken != "" {
+		o.RefreshToken = override.RefreshToken
+	}
+}
+
+// redacted masks the secrets
+func (o OAuth2) redacted() *OAuth2 {
+	o.ClientSecret = maskValue(o.ClientSecret)
+	o.RefreshToken = maskValue(o.RefreshToken)
+	return &o
+}
+
+func (o *OAuth2) trimSpace() {
+	o.TokenURL = strings.TrimSpace(o.TokenURL)
+	o.ClientID = strings.TrimSpace(o.ClientID)
+	o.ClientSecret = strings.TrimSpace(o.ClientSecret)
+	o.Audience = strings.TrimSpace(o.Audience)
+	o.GrantType = strings.TrimSpace(o.GrantType)
+	o.RefreshToken = strings.TrimSpace(o.RefreshToken)
+}
@@ -0,0 +1,53 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOAuth2Grant(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("client_credentials", (&OAuth2{}).Grant())
+	assert.Equal("refresh_token", (&OAuth2{GrantType: "refresh_token"}).Grant())
+}
+
+func TestOAuth2Merge(t *testing.T) {
+	assert := assert.New(t)
+
+	config := &Config{}
+	config.Merge(&Config{OAuth2: &OAuth2{TokenURL: "https://auth.ex.io/token", ClientID: "gulp", ClientSecret: "s3cret", Scopes: StringList{"read"}}})
+	config.Merge(&Config{OAuth2: &OAuth2{ClientSecret: "other", Audience: "https://api.ex.io"}})
+	config.Merge(&Config{})
+
+	assert.Equal(&OAuth2{
+		TokenURL:     "https://auth.ex.io/token",
+		ClientID:     "gulp",
+		ClientSecret: "other",
+		Scopes:       StringList{"read"},
+		Audience:     "https://api.ex.io",
+	}, config.OAuth2)
+}
+
+func TestOAuth2Redacted(t *testing.T) {
+	assert := assert.New(t)
+
+	config := &Config{OAuth2: &OAuth2{ClientID: "gulp", ClientSecret: "s3cret", RefreshToken: "abc123"}}
+	redacted := config.Redacted()
+
+	assert.Equal("gulp", redacted.OAuth2.ClientID)
+	assert.Equal("********", redacted.OAuth2.ClientSecret)
+	assert.Equal("********", redacted.OAuth2.RefreshToken)
+	assert.Equal("s3cret", config.OAuth2.ClientSecret)
+}
+
+func TestOAuth2Validate(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Empty(validateData("test.yml", []byte("oauth2:\n  token_url: https://auth.ex.io/token\n  client_id: gulp\n  scopes: read\n")))
+
+	problems := validateData("test.yml", []byte("oauth2:\n  token_url: https://auth.ex.io/token\n  grant_type: password\n"))
+	assert.Len(problems, 1)
+	assert.Equal("test.yml:3: invalid value 'password' for 'oauth2.grant_type', expected 'client_credentials' or 'refresh_token'", problems[0].String())
+}
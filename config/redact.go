@@ -0,0 +1,199 @@
+package config
+
+import (
+	"net/url"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// secretMask replaces secret values when the configuration is displayed
+const secretMask = "********"
+
+// sensitiveHeaders contains parts of header names that usually carry credentials
+var sensitiveHeaders = []string{"authorization", "cookie", "token", "secret", "key", "password", "session", "signature"}
+
+// Redacted returns a copy of the configuration with the defaults filled in and any secrets masked,
+// so that it can be displayed safely
+func (gc *Config) Redacted() *Config {
+	redacted := &Config{Sources: gc.Sources}
+	redacted.Merge(gc)
+	redacted.Profiles = nil
+
+	if redacted.Timeout == "" {
+		redacted.Timeout = DefaultTimeout.String()
+	}
+
+	redacted.Flags.FollowRedirects = NewBool(gc.FollowRedirects())
+	redacted.Flags.UseColor = NewBool(gc.UseColor())
+	redacted.Flags.VerifyTLS = NewBool(gc.VerifyTLS())
+
+	redacted.Headers = redacted.Headers.redacted()
+	redacted.Query = redacted.Query.redacted()
+
+	if strings.HasPrefix(redacted.ClientAuth.Key, "-----BEGIN") {
+		redacted.ClientAuth.Key = secretMask
+	}
+	redacted.ClientAuth.Passphrase = maskValue(redacted.ClientAuth.Passphrase)
+	redacted.Auth = redacted.Auth.redacted()
+	if redacted.OAuth2 != nil {
+		redacted.OAuth2 = redacted.OAuth2.redacted()
+	}
+	if redacted.AWSSigV4 != nil {
+		redacted.AWSSigV4 = redacted.AWSSigV4.redacted()
+	}
+	if redacted.Signing != nil {
+		redacted.Signing = redacted.Signing.redacted()
+	}
+	if redacted.JWT != nil {
+		redacted.JWT = redacted.JWT.redacted()
+	}
+
+	for pattern, h := range redacted.Hosts {
+		if h == nil {
+			continue
+		}
+
+		masked := &Host{}
+		masked.merge(h)
+		masked.Headers = masked.Headers.redacted()
+
+		if strings.HasPrefix(masked.ClientAuth.Key, "-----BEGIN") {
+			masked.ClientAuth.Key = secretMask
+		}
+		masked.ClientAuth.Passphrase = maskValue(masked.ClientAuth.Passphrase)
+		redacted.Hosts[pattern] = masked
+	}
+
+	return redacted
+}
+
+// maskValue hides the whole value, unless it is empty
+func maskValue(value string) string {
+	if value == "" {
+		return ""
+	}
+
+	return secretMask
+}
+
+// minSecretLength is the length below which secrets aren't masked inside other values, since a short password
+// would match all over the output. The known locations (see MaskURL and IsSensitiveHeader) are masked regardless.
+const minSecretLength = 8
+
+// MaskSecrets hides every occurrence of the secrets in the value
+func MaskSecrets(value string, secrets []string) string {
+	// Replace the longest secrets first so that a secret containing another one is fully masked
+	sorted := append([]string(nil), secrets...)
+	sort.Slice(sorted, func(i, j int) bool { return len(sorted[i]) > len(sorted[j]) })
+
+	for _, secret := range sorted {
+		if len(secret) >= minSecretLength {
+			value = strings.ReplaceAll(value, secret, secretMask)
+		}
+	}
+
+	return value
+}
+
+// MaskURL hides the password of the URL's user info, and the values of the query parameters that are likely to
+// contain credentials or are one of the names (ie. an API key parameter). The rest of the query string is kept as is.
+func MaskURL(rawURL string, names ...string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+
+	// The mask is added after encoding, since the URL would escape it
+	userinfo := ""
+	if _, ok := u.User.Password(); ok {
+		userinfo = url.User(u.User.Username()).String() + ":" + secretMask + "@"
+		u.User = nil
+	}
+
+	if u.RawQuery != "" {
+		pairs := strings.Split(u.RawQuery, "&")
+		for i, pair := range pairs {
+			key, _, hasValue := strings.Cut(pair, "=")
+			name, err := url.QueryUnescape(key)
+			if err != nil {
+				name = key
+			}
+
+			if hasValue && (IsSensitiveHeader(name) || slices.Contains(names, name)) {
+				pairs[i] = key + "=" + secretMask
+			}
+		}
+		u.RawQuery = strings.Join(pairs, "&")
+	}
+
+	if userinfo != "" {
+		return strings.Replace(u.String(), "//", "//"+userinfo, 1)
+	}
+	return u.String()
+}
+
+// redacted returns a copy of the headers with the values of the sensitive ones masked
+func (hm HeaderMap) redacted() HeaderMap {
+	if hm == nil {
+		return nil
+	}
+
+	headers := make(HeaderMap, len(hm))
+	for k, v := range hm {
+		headers[k] = v
+		if IsSensitiveHeader(k) {
+			headers[k] = make(StringList, len(v))
+			for i, value := range v {
+				headers[k][i] = MaskSecret(value)
+			}
+		}
+	}
+
+	return headers
+}
+
+// redacted masks the values of parameters that are likely to contain credentials, ie. api_key
+func (qm QueryMap) redacted() QueryMap {
+	if qm == nil {
+		return nil
+	}
+
+	query := make(QueryMap, len(qm))
+	for k, v := range qm {
+		query[k] = v
+		if IsSensitiveHeader(k) {
+			query[k] = make(StringList, len(v))
+			for i, value := range v {
+				query[k][i] = MaskSecret(value)
+			}
+		}
+	}
+
+	return query
+}
+
+// IsSensitiveHeader checks whether the header is likely to contain a credential
+func IsSensitiveHeader(name string) bool {
+	name = strings.ToLower(name)
+	for _, s := range sensitiveHeaders {
+		if strings.Contains(name, s) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// MaskSecret hides a secret value, keeping the authentication scheme (ie. "Bearer") if there is one
+func MaskSecret(value string) string {
+	if value == "" {
+		return ""
+	}
+
+	if scheme, _, ok := strings.Cut(value, " "); ok {
+		return scheme + " " + secretMask
+	}
+
+	return secretMask
+}
@@ -0,0 +1,239 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// SchemaID is where the published schema can be found, ie. for a yaml-language-server modeline
+const SchemaID = "https://raw.githubusercontent.com/thoom/gulp/master/gulp.schema.json"
+
+// durationPattern matches the timeouts that ParseTimeout accepts: a number of seconds or a Go duration
+const durationPattern = `^\s*(\+?[0-9]+|\+?(([0-9]+(\.[0-9]*)?|\.[0-9]+)(ns|us|µs|μs|ms|s|m|h))+)\s*$`
+
+// referencePattern matches values that contain a ${VAR} or $(file:...)/$(cmd:...) reference
+const referencePattern = `\$[{(]`
+
+// Schema is the subset of JSON Schema used to describe the configuration file
+type Schema struct {
+	Schema      string             `json:"$schema,omitempty"`
+	ID          string             `json:"$id,omitempty"`
+	Ref         string             `json:"$ref,omitempty"`
+	Title       string             `json:"title,omitempty"`
+	Description string             `json:"description,omitempty"`
+	Type        interface{}        `json:"type,omitempty"`
+	Properties  map[string]*Schema `json:"properties,omitempty"`
+
+	// AdditionalProperties is false for structs and the schema of the values for maps
+	AdditionalProperties interface{} `json:"additionalProperties,omitempty"`
+
+	Items   *Schema            `json:"items,omitempty"`
+	AnyOf   []*Schema          `json:"anyOf,omitempty"`
+	Enum    []interface{}      `json:"enum,omitempty"`
+	Pattern string             `json:"pattern,omitempty"`
+	Minimum *int               `json:"minimum,omitempty"`
+	Defs    map[string]*Schema `json:"$defs,omitempty"`
+
+	// rule is the validate tag of the field, used to describe invalid values and to check files
+	rule string
+}
+
+// configSchema is built once since the structs don't change at runtime
+var configSchema = ConfigSchema()
+
+// ConfigSchema builds the JSON Schema of the configuration file from the Config struct.
+// The same schema is used to validate configuration files when they're loaded.
+func ConfigSchema() *Schema {
+	schema := structSchema(reflect.TypeOf(Config{}))
+	schema.Schema = "https://json-schema.org/draft/2020-12/schema"
+	schema.ID = SchemaID
+	schema.Title = "gulp configuration"
+	schema.Defs = map[string]*Schema{
+		"reference": {
+			Description: "A ${VAR}, $(file:path) or $(cmd:command) reference that is resolved when the configuration is loaded",
+			Type:        "string",
+			Pattern:     referencePattern,
+		},
+	}
+
+	return schema
+}
+
+// structSchema describes a struct as an object that only allows the fields it unmarshals
+func structSchema(t reflect.Type) *Schema {
+	schema := &Schema{Type: "object", Properties: map[string]*Schema{}, AdditionalProperties: false}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := jsonName(field)
+		if name == "" {
+			continue
+		}
+
+		property := typeSchema(field.Type, field.Tag.Get("validate"))
+		property.Description = field.Tag.Get("description")
+		schema.Properties[name] = property
+	}
+
+	return schema
+}
+
+// typeSchema describes the values that can be unmarshaled into the type
+func typeSchema(t reflect.Type, rule string) *Schema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch t {
+	case reflect.TypeOf(Config{}):
+		// Profiles are full configurations
+		return &Schema{Ref: "#"}
+	case reflect.TypeOf(Bool{}):
+		return scalarSchema(rule)
+	case reflect.TypeOf(StringList{}):
+		return &Schema{AnyOf: []*Schema{scalarSchema(""), {Type: "array", Items: scalarSchema("")}}}
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		return structSchema(t)
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: typeSchema(t.Elem(), "")}
+	case reflect.Slice:
+		return &Schema{Type: "array", Items: typeSchema(t.Elem(), rule)}
+	}
+
+	return scalarSchema(rule)
+}
+
+// scalarSchema describes the values allowed by the rule. Values with a rule can also be a reference
+// that resolves to a valid value. Plain strings accept any scalar since the loader converts them to strings.
+func scalarSchema(rule string) *Schema {
+	reference := &Schema{Ref: "#/$defs/reference"}
+	switch rule {
+	case "bool":
+		return &Schema{rule: rule, AnyOf: []*Schema{
+			{Type: "boolean"},
+			{Type: "integer", Enum: []interface{}{1, 0}},
+			{Type: "string", Enum: boolEnum()},
+			reference,
+		}}
+	case "timeout":
+		minimum := 0
+		return &Schema{rule: rule, AnyOf: []*Schema{
+			{Type: "integer", Minimum: &minimum},
+			{Type: "string", Pattern: durationPattern},
+			reference,
+		}}
+	case "display":
+		return &Schema{rule: rule, AnyOf: []*Schema{
+			{Type: "string", Enum: []interface{}{"verbose", "status-code-only"}},
+			reference,
+		}}
+	}
+
+	// ie. enum=header|query
+	if values, ok := strings.CutPrefix(rule, "enum="); ok {
+		var enum []interface{}
+		for _, value := range strings.Split(values, "|") {
+			enum = append(enum, value)
+		}
+
+		return &Schema{rule: rule, AnyOf: []*Schema{{Type: "string", Enum: enum}, reference}}
+	}
+
+	return &Schema{rule: rule, Type: []string{"string", "number", "boolean"}}
+}
+
+// types returns the JSON types allowed by the schema
+func (s *Schema) types() []string {
+	switch t := s.Type.(type) {
+	case string:
+		return []string{t}
+	case []string:
+		return t
+	}
+
+	return nil
+}
+
+// allows checks whether the schema, or one of its anyOf branches, accepts the JSON type
+func (s *Schema) allows(root *Schema, jsonType string) bool {
+	return s.branch(root, jsonType) != nil
+}
+
+// branch returns the schema, or the anyOf branch, that describes values of the JSON type
+func (s *Schema) branch(root *Schema, jsonType string) *Schema {
+	s = s.deref(root)
+	for _, t := range s.types() {
+		if t == jsonType || (t == "number" && jsonType == "integer") {
+			return s
+		}
+	}
+
+	for _, b := range s.AnyOf {
+		if found := b.branch(root, jsonType); found != nil {
+			return found
+		}
+	}
+
+	return nil
+}
+
+// matches checks a scalar value against the schema's type, enum, pattern and minimum
+func (s *Schema) matches(root *Schema, jsonType, value string) bool {
+	s = s.deref(root)
+	if len(s.AnyOf) > 0 {
+		for _, b := range s.AnyOf {
+			if b.matches(root, jsonType, value) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if !s.allows(root, jsonType) {
+		return false
+	}
+
+	if len(s.Enum) > 0 {
+		found := false
+		for _, e := range s.Enum {
+			found = found || fmt.Sprint(e) == value
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	if s.Pattern != "" {
+		if ok, err := regexp.MatchString(s.Pattern, value); err != nil || !ok {
+			return false
+		}
+	}
+
+	if s.Minimum != nil {
+		if n, err := strconv.Atoi(value); err != nil || n < *s.Minimum {
+			return false
+		}
+	}
+
+	return true
+}
+
+// deref follows a $ref to the root schema or one of its definitions
+func (s *Schema) deref(root *Schema) *Schema {
+	switch {
+	case s.Ref == "#":
+		return root
+	case strings.HasPrefix(s.Ref, "#/$defs/"):
+		if def, ok := root.Defs[strings.TrimPrefix(s.Ref, "#/$defs/")]; ok {
+			return def
+		}
+	}
+
+	return s
+}
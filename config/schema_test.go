@@ -0,0 +1,109 @@
+package config
+
+import (
+	"reflect"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfigSchemaProperties(t *testing.T) {
+	assert := assert.New(t)
+
+	schema := ConfigSchema()
+	assert.Equal(SchemaID, schema.ID)
+	assert.Equal(false, schema.AdditionalProperties)
+
+	for _, tt := range []struct {
+		t      reflect.Type
+		schema *Schema
+	}{
+		{reflect.TypeOf(Config{}), schema},
+		{reflect.TypeOf(ClientAuth{}), schema.Properties["client_auth"]},
+		{reflect.TypeOf(ConfigFlags{}), schema.Properties["flags"]},
+		{reflect.TypeOf(Host{}), schema.Properties["hosts"].AdditionalProperties.(*Schema)},
+	} {
+		var names []string
+		for i := 0; i < tt.t.NumField(); i++ {
+			if name := jsonName(tt.t.Field(i)); name != "" {
+				names = append(names, name)
+				assert.NotEmpty(tt.schema.Properties[name].Description, name)
+			}
+		}
+		assert.Len(tt.schema.Properties, len(names), tt.t.Name())
+	}
+
+	assert.Nil(schema.Properties["sources"])
+	assert.Equal("#", schema.Properties["profiles"].AdditionalProperties.(*Schema).Ref)
+	assert.Equal("timeout", schema.Properties["timeout"].rule)
+	assert.Equal("file", schema.Properties["client_auth"].Properties["cert"].rule)
+}
+
+func TestDurationPatternMatchesParseTimeout(t *testing.T) {
+	assert := assert.New(t)
+
+	pattern := regexp.MustCompile(durationPattern)
+	for _, value := range []string{"30", " 30 ", "+30", "0", "500ms", "1m30s", "1.5h", ".5s", "2us", "3µs", "1h2m3s4ms",
+		"-5", "-5s", "30 seconds", "1.5", "abc", "5d", "s", "1m 30s"} {
+		_, err := ParseTimeout(value)
+		assert.Equal(err == nil, pattern.MatchString(value), value)
+	}
+}
+
+func TestSchemaMatches(t *testing.T) {
+	assert := assert.New(t)
+
+	schema := ConfigSchema()
+	timeout := schema.Properties["timeout"]
+	assert.True(timeout.matches(schema, "integer", "30"))
+	assert.False(timeout.matches(schema, "integer", "-30"))
+	assert.True(timeout.matches(schema, "string", "1m"))
+	assert.False(timeout.matches(schema, "boolean", "true"))
+
+	flag := schema.Properties["flags"].Properties["use_color"]
+	assert.True(flag.matches(schema, "boolean", "false"))
+	assert.True(flag.matches(schema, "string", "true"))
+	assert.True(flag.matches(schema, "string", "Yes"))
+	assert.True(flag.matches(schema, "integer", "0"))
+	assert.False(flag.matches(schema, "string", "yEs"))
+	assert.False(flag.matches(schema, "integer", "2"))
+
+	display := schema.Properties["display"]
+	assert.True(display.matches(schema, "string", "verbose"))
+	assert.True(display.matches(schema, "string", "${GULP_DISPLAY}"))
+	assert.False(display.matches(schema, "string", "quiet"))
+
+	url := schema.Properties["url"]
+	assert.True(url.matches(schema, "integer", "123"))
+	assert.True(url.matches(schema, "string", "https://api.ex.io"))
+}
+
+func TestValidateDataSchemaShapes(t *testing.T) {
+	assert := assert.New(t)
+	t.Setenv("GULP_TEST_TIMEOUT", "1m")
+	t.Setenv("GULP_TEST_FLAG", "sometimes")
+
+	problems := validateData("test.yml", []byte(`timeout: ${GULP_TEST_TIMEOUT}
+extends:
+  nested: true
+flags:
+  verify_tls: ${GULP_TEST_FLAG}
+  use_color: "no"
+client_auth: /etc/certs
+hosts:
+  api.ex.io: []
+`))
+
+	var messages []string
+	for _, p := range problems {
+		messages = append(messages, p.String())
+	}
+
+	assert.Equal([]string{
+		"test.yml:3: expected a list for 'extends'",
+		"test.yml:5: invalid boolean 'sometimes' for 'flags.verify_tls', expected true or false",
+		"test.yml:7: expected a mapping for 'client_auth'",
+		"test.yml:9: expected a mapping for 'hosts.api.ex.io'",
+	}, messages)
+}
@@ -0,0 +1,72 @@
+package config
+
+import "strings"
+
+// Signing contains the settings used to add an HMAC signature header to each request
+type Signing struct {
+	Algorithm       string `json:"algorithm,omitempty" validate:"enum=hmac-sha256|hmac-sha512|hmac-sha1" description:"The HMAC algorithm (default hmac-sha256)"`
+	Key             string `json:"key" description:"The signing key, usually a ${VAR} or $(file:path) reference"`
+	KeyEncoding     string `json:"key_encoding,omitempty" validate:"enum=raw|base64|hex" description:"How the key is encoded (default raw)"`
+	Template        string `json:"template" description:"The Go template of the string to sign, ie. {{.Method}}\\n{{.Path}}\\n{{.Timestamp}}\\n{{.BodySHA256}}"`
+	Header          string `json:"header" description:"The header the signature is sent in"`
+	Value           string `json:"value,omitempty" description:"The Go template of the header value (default {{.Signature}})"`
+	Encoding        string `json:"encoding,omitempty" validate:"enum=hex|base64" description:"How the signature is encoded (default hex)"`
+	TimestampHeader string `json:"timestamp_header,omitempty" description:"A header the timestamp is sent in, ie. X-Timestamp"`
+	TimestampFormat string `json:"timestamp_format,omitempty" validate:"enum=unix|unix_ms|rfc3339" description:"The format of the timestamp (default unix)"`
+}
+
+// merge overlays the settings set in override
+func (s *Signing) merge(override *Signing) {
+	if override.Algorithm != "" {
+		s.Algorithm = override.Algorithm
+	}
+
+	if override.Key != "" {
+		s.Key = override.Key
+	}
+
+	if override.KeyEncoding != "" {
+		s.KeyEncoding = override.KeyEncoding
+	}
+
+	if override.Template != "" {
+		s.Template = override.Template
+	}
+
+	if override.Header != "" {
+		s.Header = override.Header
+	}
+
+	if override.Value != "" {
+		s.Value = override.Value
+	}
+
+	if override.Encoding != "" {
+		s.Encoding = override.Encoding
+	}
+
+	if override.TimestampHeader != "" {
+		s.TimestampHeader = override.TimestampHeader
+	}
+
+	if override.TimestampFormat != "" {
+		s.TimestampFormat = override.TimestampFormat
+	}
+}
+
+// redacted masks the key
+func (s Signing) redacted() *Signing {
+	s.Key = maskValue(s.Key)
+	return &s
+}
+
+// trimSpace cleans up the settings, except for the templates where whitespace is part of the string to sign
+func (s *Signing) trimSpace() {
+	s.Algorithm = strings.TrimSpace(s.Algorithm)
+	s.Key = strings.TrimSpace(s.Key)
+	s.KeyEncoding = strings.TrimSpace(s.KeyEncoding)
+	s.Header = strings.TrimSpace(s.Header)
+	s.Encoding = strings.TrimSpace(s.Encoding)
+	s.TimestampHeader = strings.TrimSpace(s.TimestampHeader)
+	s.TimestampFormat = strings.TrimSpace(s.TimestampFormat)
+}
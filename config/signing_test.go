@@ -0,0 +1,49 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSigningMerge(t *testing.T) {
+	assert := assert.New(t)
+
+	config := &Config{}
+	config.Merge(&Config{Signing: &Signing{Key: "abc", Template: "{{.Method}}", Header: "X-Signature"}})
+	config.Merge(&Config{Signing: &Signing{Key: "def", Encoding: "base64"}})
+	config.Merge(&Config{})
+
+	assert.Equal(&Signing{Key: "def", Template: "{{.Method}}", Header: "X-Signature", Encoding: "base64"}, config.Signing)
+}
+
+func TestSigningRedacted(t *testing.T) {
+	assert := assert.New(t)
+
+	config := &Config{Signing: &Signing{Key: "abc", Header: "X-Signature"}}
+	redacted := config.Redacted()
+
+	assert.Equal("********", redacted.Signing.Key)
+	assert.Equal("X-Signature", redacted.Signing.Header)
+	assert.Equal("abc", config.Signing.Key)
+}
+
+func TestSigningTrimSpace(t *testing.T) {
+	assert := assert.New(t)
+
+	signing := Signing{Key: " abc ", Header: " X-Signature ", Template: "{{.Method}}\n", Value: " {{.Signature}}"}
+	signing.trimSpace()
+
+	// Whitespace in the templates is part of the signature
+	assert.Equal(Signing{Key: "abc", Header: "X-Signature", Template: "{{.Method}}\n", Value: " {{.Signature}}"}, signing)
+}
+
+func TestSigningValidate(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Empty(validateData("test.yml", []byte("signing:\n  key: ${KEY:-abc}\n  header: X-Signature\n  template: |-\n    {{.Method}}\n    {{.Path}}\n")))
+
+	problems := validateData("test.yml", []byte("signing:\n  algorithm: md5\n"))
+	assert.Len(problems, 1)
+	assert.Equal("test.yml:2: invalid value 'md5' for 'signing.algorithm', expected 'hmac-sha256', 'hmac-sha512' or 'hmac-sha1'", problems[0].String())
+}
@@ -0,0 +1,362 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+// Problem describes a single issue found while validating a configuration file
+type Problem struct {
+	File    string
+	Line    int
+	Message string
+}
+
+func (p Problem) String() string {
+	if p.Line > 0 {
+		return fmt.Sprintf("%s:%d: %s", p.File, p.Line, p.Message)
+	}
+
+	return fmt.Sprintf("%s: %s", p.File, p.Message)
+}
+
+// ValidationError is returned when a configuration file contains one or more problems
+type ValidationError struct {
+	Problems []Problem
+}
+
+func (ve *ValidationError) Error() string {
+	lines := []string{"invalid configuration:"}
+	for _, p := range ve.Problems {
+		lines = append(lines, "  "+p.String())
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// Validate checks every file that LoadConfiguration would merge (including the files they extend).
+// It returns the files that were checked along with every problem that was found.
+func Validate(fileName string) ([]string, []Problem) {
+	var files []string
+	var problems []Problem
+	for _, f := range discoverFiles(fileName) {
+		checked, found := validateTree(f, nil)
+		files = append(files, checked...)
+		problems = append(problems, found...)
+	}
+
+	return files, problems
+}
+
+// validateTree validates a file and the files it extends
+func validateTree(fileName string, chain []string) ([]string, []Problem) {
+	chain, err := extendChain(chain, fileName)
+	if err != nil {
+		return nil, []Problem{{File: fileName, Message: err.Error()}}
+	}
+
+	dat, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, []Problem{{File: fileName, Message: fmt.Sprintf("could not load configuration%s: %v", includeChain(chain), err)}}
+	}
+
+	problems := validateData(fileName, dat)
+	files := []string{fileName}
+
+	var parsed struct {
+		Extends StringList `json:"extends"`
+	}
+	if yaml.Unmarshal(dat, &parsed) != nil {
+		return files, problems
+	}
+
+	for _, parent := range parsed.Extends {
+		parent, err := Interpolate(parent)
+		if err != nil {
+			continue
+		}
+
+		checked, found := validateTree(extendsPath(fileName, parent), chain)
+		files = append(files, checked...)
+		problems = append(problems, found...)
+	}
+
+	return files, problems
+}
+
+// validateData checks the YAML for unknown keys and invalid values
+func validateData(fileName string, dat []byte) []Problem {
+	var root yamlv3.Node
+	if err := yamlv3.Unmarshal(dat, &root); err != nil {
+		return []Problem{{File: fileName, Message: err.Error()}}
+	}
+
+	// A file with only comments doesn't have any values to check
+	if root.Kind == 0 {
+		return nil
+	}
+
+	v := &validator{file: fileName, root: configSchema}
+	v.validate(&root, configSchema, "", false)
+	return v.problems
+}
+
+type validator struct {
+	file     string
+	root     *Schema
+	problems []Problem
+}
+
+func (v *validator) addf(node *yamlv3.Node, format string, args ...interface{}) {
+	v.problems = append(v.problems, Problem{File: v.file, Line: node.Line, Message: fmt.Sprintf(format, args...)})
+}
+
+// validate walks the YAML node alongside the schema that describes it (see ConfigSchema).
+// Values in profiles are deferred: references and file paths are only checked once a profile is selected.
+func (v *validator) validate(node *yamlv3.Node, schema *Schema, path string, deferred bool) {
+	switch node.Kind {
+	case yamlv3.DocumentNode:
+		if len(node.Content) > 0 {
+			v.validate(node.Content[0], schema, path, deferred)
+		}
+		return
+	case yamlv3.AliasNode:
+		v.validate(node.Alias, schema, path, deferred)
+		return
+	}
+
+	if node.Kind == yamlv3.ScalarNode && node.Tag == "!!null" {
+		return
+	}
+
+	// Profiles refer back to the whole configuration and are resolved when they're applied (see ApplyProfile)
+	if schema.Ref == "#" {
+		deferred = true
+	}
+	schema = schema.deref(v.root)
+
+	switch node.Kind {
+	case yamlv3.MappingNode:
+		object := schema.branch(v.root, "object")
+		if object == nil {
+			v.addf(node, "expected %s for '%s'", expected(v.root, schema), path)
+			return
+		}
+
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			if property, ok := object.Properties[key.Value]; ok {
+				v.validate(value, property, joinPath(path, key.Value), deferred)
+			} else if values, ok := object.AdditionalProperties.(*Schema); ok {
+				v.validate(value, values, joinPath(path, key.Value), deferred)
+			} else {
+				v.addf(key, "unknown key '%s'%s%s", key.Value, inPath(path), suggestKey(object, key.Value))
+			}
+		}
+	case yamlv3.SequenceNode:
+		list := schema.branch(v.root, "array")
+		if list == nil {
+			v.addf(node, "expected %s for '%s'", expected(v.root, schema), path)
+			return
+		}
+
+		for _, item := range node.Content {
+			v.validate(item, list.Items, path, deferred)
+		}
+	case yamlv3.ScalarNode:
+		if !schema.allows(v.root, "string") && !schema.allows(v.root, "number") && !schema.allows(v.root, "boolean") {
+			v.addf(node, "expected %s for '%s'", expected(v.root, schema), path)
+			return
+		}
+
+		v.validateValue(node, schema, path, deferred)
+	}
+}
+
+// validateValue checks a scalar value against the schema, using the rule to describe invalid values
+func (v *validator) validateValue(node *yamlv3.Node, schema *Schema, path string, deferred bool) {
+	value, jsonType := node.Value, scalarType(node)
+	if strings.Contains(value, "$") {
+		if deferred {
+			return
+		}
+
+		resolved, err := Interpolate(value)
+		if err != nil {
+			v.addf(node, "%v", err)
+			return
+		}
+
+		// The loader reads references as strings
+		value, jsonType = resolved, "string"
+	}
+
+	if jsonType == "boolean" {
+		value = strconv.FormatBool(yamlBools[value])
+	}
+
+	if !schema.matches(v.root, jsonType, value) {
+		switch schema.rule {
+		case "bool":
+			v.addf(node, "invalid boolean '%s' for '%s', expected true or false", value, path)
+		case "timeout":
+			v.addf(node, "invalid timeout '%s' for '%s', expected a duration (ie. 500ms, 1m30s) or a number of seconds", value, path)
+		case "display":
+			v.addf(node, "invalid display '%s' for '%s', expected 'verbose' or 'status-code-only'", value, path)
+		default:
+			if values, ok := strings.CutPrefix(schema.rule, "enum="); ok {
+				v.addf(node, "invalid value '%s' for '%s', expected %s", value, path, quoteChoices(strings.Split(values, "|")))
+				return
+			}
+
+			v.addf(node, "expected %s for '%s'", expected(v.root, schema), path)
+		}
+		return
+	}
+
+	if schema.rule == "file" {
+		value = strings.TrimSpace(value)
+		if deferred || value == "" || strings.HasPrefix(value, "-----BEGIN") {
+			return
+		}
+
+		f, err := os.Open(resolvePath(filepath.Dir(v.file), value))
+		if err != nil {
+			v.addf(node, "could not read '%s' for '%s': %v", value, path, err)
+			return
+		}
+		f.Close()
+	}
+}
+
+// yamlBools contains the booleans understood by the YAML 1.1 parser used to load the configuration
+var yamlBools = map[string]bool{
+	"y": true, "Y": true, "yes": true, "Yes": true, "YES": true,
+	"true": true, "True": true, "TRUE": true, "on": true, "On": true, "ON": true,
+	"n": false, "N": false, "no": false, "No": false, "NO": false,
+	"false": false, "False": false, "FALSE": false, "off": false, "Off": false, "OFF": false,
+}
+
+// scalarType returns the JSON type the loader reads the scalar as.
+// The YAML 1.1 spellings (ie. yes, off) are only booleans if they aren't quoted.
+func scalarType(node *yamlv3.Node) string {
+	switch node.Tag {
+	case "!!bool":
+		return "boolean"
+	case "!!int":
+		return "integer"
+	case "!!float":
+		return "number"
+	}
+
+	if _, ok := yamlBools[node.Value]; ok && node.Style == 0 {
+		return "boolean"
+	}
+
+	return "string"
+}
+
+// expected describes the shape of the values allowed by the schema
+func expected(root, schema *Schema) string {
+	switch {
+	case schema.allows(root, "object"):
+		return "a mapping"
+	case schema.allows(root, "array"):
+		return "a list"
+	}
+
+	return "a string"
+}
+
+// jsonName returns the key a field is unmarshaled from, or "" if it isn't part of the file
+func jsonName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "-" || !field.IsExported() {
+		return ""
+	}
+
+	return name
+}
+
+// suggestKey looks for a known key that is close to the unknown one, ie. follow_redirect
+func suggestKey(object *Schema, key string) string {
+	names := make([]string, 0, len(object.Properties))
+	for name := range object.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	best, bestDistance := "", 3
+	for _, name := range names {
+		if d := distance(strings.ToLower(key), name); d < bestDistance {
+			best, bestDistance = name, d
+		}
+	}
+
+	if best == "" {
+		return ""
+	}
+
+	return fmt.Sprintf(", did you mean '%s'?", best)
+}
+
+// distance calculates the Levenshtein distance between two strings
+func distance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+
+	return prev[len(b)]
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+
+	return path + "." + key
+}
+
+func inPath(path string) string {
+	if path == "" {
+		return ""
+	}
+
+	return fmt.Sprintf(" in '%s'", path)
+}
+
+// quoteChoices lists the values as 'a', 'b' or 'c'
+func quoteChoices(values []string) string {
+	quoted := make([]string, len(values))
+	for i, value := range values {
+		quoted[i] = "'" + value + "'"
+	}
+
+	if len(quoted) == 1 {
+		return quoted[0]
+	}
+
+	return strings.Join(quoted[:len(quoted)-1], ", ") + " or " + quoted[len(quoted)-1]
+}
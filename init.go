@@ -0,0 +1,303 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/thoom/gulp/client"
+	"github.com/thoom/gulp/config"
+	"github.com/thoom/gulp/output"
+)
+
+// initAnswers contains the values collected by gulp init
+type initAnswers struct {
+	URL        string
+	Headers    [][2]string
+	Display    string
+	Timeout    string
+	ClientAuth config.ClientAuth
+}
+
+// prompter asks questions on the output and reads the answers one line at a time
+type prompter struct {
+	in  *bufio.Reader
+	bo  *output.BuffOut
+	eof bool
+}
+
+// ask prints the question and returns the answer, or the default if the answer is empty.
+// Invalid answers are reported and the question is asked again until the input runs out.
+func (p *prompter) ask(question, def string, check func(string) error) (string, error) {
+	for {
+		if def != "" {
+			fmt.Fprintf(p.bo.Out, "%s [%s]: ", question, def)
+		} else {
+			fmt.Fprintf(p.bo.Out, "%s: ", question)
+		}
+
+		answer := def
+		if !p.eof {
+			line, err := p.in.ReadString('\n')
+			if err == io.EOF {
+				p.eof = true
+				fmt.Fprintln(p.bo.Out)
+			} else if err != nil {
+				return "", fmt.Errorf("could not read answer: %s", err)
+			}
+
+			if line = strings.TrimSpace(line); line != "" {
+				answer = line
+			}
+		}
+
+		if check == nil {
+			return answer, nil
+		}
+
+		err := check(answer)
+		if err == nil {
+			return answer, nil
+		}
+
+		if p.eof {
+			return "", err
+		}
+		p.bo.PrintErr("", err)
+	}
+}
+
+// initConfig asks for the common settings and writes them to a new configuration file
+func initConfig(in io.Reader, bo *output.BuffOut) int {
+	fileName := *configFlag
+	if _, err := os.Stat(fileName); err == nil && !*forceFlag {
+		bo.PrintErr("", fmt.Errorf("'%s' already exists, use -force to overwrite it", fileName))
+		return 1
+	}
+
+	answers, err := askInitQuestions(&prompter{in: bufio.NewReader(in), bo: bo})
+	if err != nil {
+		bo.PrintErr("", err)
+		return 1
+	}
+
+	if err := os.WriteFile(fileName, []byte(buildInitConfig(answers)), 0644); err != nil {
+		bo.PrintErr("could not write configuration: ", err)
+		return 1
+	}
+
+	bo.PrintStoplight("Created "+fileName, false)
+	return 0
+}
+
+func askInitQuestions(p *prompter) (*initAnswers, error) {
+	var err error
+	answers := &initAnswers{}
+
+	answers.URL, err = p.ask("Base URL (ie. https://api.ex.io)", "", checkInitURL)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		header, err := p.ask("Default header (ie. Accept: application/json), leave empty to finish", "", checkInitHeader)
+		if err != nil {
+			return nil, err
+		}
+
+		if header == "" {
+			break
+		}
+
+		name, value, _ := strings.Cut(header, ":")
+		answers.Headers = append(answers.Headers, [2]string{strings.TrimSpace(name), strings.TrimSpace(value)})
+	}
+
+	answers.Display, err = p.ask("Display mode (verbose, status-code-only), leave empty for the response body only", "", checkInitDisplay)
+	if err != nil {
+		return nil, err
+	}
+
+	answers.Timeout, err = p.ask("Timeout", config.DefaultTimeout.String(), func(value string) error {
+		_, err := config.ParseTimeout(value)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Don't write the default timeout
+	if d, _ := config.ParseTimeout(answers.Timeout); d == config.DefaultTimeout {
+		answers.Timeout = ""
+	}
+
+	answers.ClientAuth.Cert, err = p.ask("Client certificate file, leave empty to skip", "", func(value string) error {
+		if err := checkInitFile(value); err != nil || value == "" {
+			return err
+		}
+
+		cert := config.ClientAuth{Cert: value}
+		if !cert.IsPKCS12() {
+			return nil
+		}
+
+		// Like an encrypted key, the passphrase is asked for when a request is sent
+		err := client.ValidateClientAuth(cert)
+		if errors.Is(err, client.ErrPassphraseRequired) {
+			return nil
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// PKCS#12 files include the key
+	if answers.ClientAuth.Cert != "" && !answers.ClientAuth.IsPKCS12() {
+		answers.ClientAuth.Key, err = p.ask("Client certificate key file", "", func(value string) error {
+			if value == "" {
+				return fmt.Errorf("a key is required when using a client certificate")
+			}
+
+			// The passphrase of an encrypted key is asked for when a request is sent
+			err := client.ValidateClientAuth(config.ClientAuth{Cert: answers.ClientAuth.Cert, Key: value})
+			if errors.Is(err, client.ErrPassphraseRequired) {
+				return nil
+			}
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	answers.ClientAuth.CA, err = p.ask("Custom CA certificate file, leave empty to skip", "", func(value string) error {
+		if value == "" {
+			return nil
+		}
+
+		return client.ValidateClientAuth(config.ClientAuth{CA: value})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return answers, nil
+}
+
+func checkInitURL(value string) error {
+	if value == "" {
+		return nil
+	}
+
+	u, err := url.Parse(value)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid URL '%s', expected ie. https://api.ex.io", value)
+	}
+
+	return nil
+}
+
+func checkInitHeader(value string) error {
+	if value == "" {
+		return nil
+	}
+
+	name, _, ok := strings.Cut(value, ":")
+	if !ok || strings.TrimSpace(name) == "" {
+		return fmt.Errorf("invalid header '%s', expected 'Name: value'", value)
+	}
+
+	return nil
+}
+
+func checkInitDisplay(value string) error {
+	if value != "" && value != "verbose" && value != "status-code-only" {
+		return fmt.Errorf("invalid display '%s', expected 'verbose' or 'status-code-only'", value)
+	}
+
+	return nil
+}
+
+func checkInitFile(value string) error {
+	if value == "" {
+		return nil
+	}
+
+	if _, err := os.ReadFile(value); err != nil {
+		return fmt.Errorf("could not read '%s': %s", value, err)
+	}
+
+	return nil
+}
+
+// buildInitConfig writes the answers as YAML, with comments describing each option.
+// Options that weren't answered are included as commented out examples.
+func buildInitConfig(answers *initAnswers) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "# yaml-language-server: $schema=%s\n", config.SchemaID)
+	sb.WriteString("# gulp configuration, created by `gulp init`.\n")
+	sb.WriteString("# See https://github.com/thoom/gulp#configuration for every option.\n\n")
+
+	sb.WriteString("# The URL used when a request only passes a path, ie. gulp /users\n")
+	if answers.URL != "" {
+		fmt.Fprintf(&sb, "url: %s\n\n", yamlString(answers.URL))
+	} else {
+		sb.WriteString("# url: https://api.ex.io\n\n")
+	}
+
+	sb.WriteString("# Headers sent with every request, override them with -H\n")
+	if len(answers.Headers) > 0 {
+		sb.WriteString("headers:\n")
+		for _, h := range answers.Headers {
+			fmt.Fprintf(&sb, "  %s: %s\n", yamlString(h[0]), yamlString(h[1]))
+		}
+		sb.WriteString("\n")
+	} else {
+		sb.WriteString("# headers:\n#   Accept: application/json\n\n")
+	}
+
+	sb.WriteString("# How responses are displayed: verbose or status-code-only (default is the response body only)\n")
+	if answers.Display != "" {
+		fmt.Fprintf(&sb, "display: %s\n\n", answers.Display)
+	} else {
+		sb.WriteString("# display: verbose\n\n")
+	}
+
+	fmt.Fprintf(&sb, "# How long to wait for the whole request, ie. 30s or 1m30s (default %s)\n", config.DefaultTimeout)
+	if answers.Timeout != "" {
+		fmt.Fprintf(&sb, "timeout: %s\n\n", yamlString(answers.Timeout))
+	} else {
+		sb.WriteString("# timeout: 30s\n\n")
+	}
+
+	sb.WriteString("# The client certificate and key used for client cert auth, and a custom CA to verify the server with\n")
+	if answers.ClientAuth.Cert != "" || answers.ClientAuth.CA != "" {
+		sb.WriteString("client_auth:\n")
+		if answers.ClientAuth.Cert != "" {
+			fmt.Fprintf(&sb, "  cert: %s\n", yamlString(answers.ClientAuth.Cert))
+			if answers.ClientAuth.Key != "" {
+				fmt.Fprintf(&sb, "  key: %s\n", yamlString(answers.ClientAuth.Key))
+			}
+		}
+		if answers.ClientAuth.CA != "" {
+			fmt.Fprintf(&sb, "  ca: %s\n", yamlString(answers.ClientAuth.CA))
+		}
+	} else {
+		sb.WriteString("# client_auth:\n#   cert: /etc/certs/client-cert.pem\n#   key: /etc/certs/client-key.pem\n#   ca: /etc/certs/ca.pem\n")
+	}
+
+	return sb.String()
+}
+
+// yamlString quotes a value so that it is always read back as the same string.
+// A JSON string is also a valid double-quoted YAML string.
+func yamlString(value string) string {
+	quoted, _ := json.Marshal(value)
+	return string(quoted)
+}
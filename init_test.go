@@ -0,0 +1,226 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/thoom/gulp/config"
+	"github.com/thoom/gulp/output"
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+// writeTestCert creates a self-signed cert and key in the directory
+func writeTestCert(t *testing.T, dir string) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "gulp test"},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyDer, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644)
+	os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDer}), 0600)
+	return certFile, keyFile
+}
+
+// writeTestP12 creates a PKCS#12 file with a self-signed cert and key in the directory
+func writeTestP12(t *testing.T, dir, passphrase string) string {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "gulp test"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := pkcs12.Modern.Encode(key, cert, nil, passphrase)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file := filepath.Join(dir, "client.p12")
+	os.WriteFile(file, data, 0600)
+	return file
+}
+
+func TestInitConfig(t *testing.T) {
+	assert := assert.New(t)
+	output.NoColor(true)
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	dir := t.TempDir()
+	certFile, keyFile := writeTestCert(t, dir)
+	testFile := filepath.Join(dir, ".gulp.yml")
+	*configFlag = testFile
+	defer func() { *configFlag = config.DefaultFileName }()
+
+	answers := strings.Join([]string{
+		"api.ex.io",
+		"https://api.ex.io",
+		"Accept: application/json",
+		"X-Team: core:platform",
+		"",
+		"loud",
+		"verbose",
+		"30",
+		certFile,
+		certFile,
+		keyFile,
+		certFile,
+	}, "\n") + "\n"
+
+	b := &bytes.Buffer{}
+	bo := &output.BuffOut{Out: b, Err: b}
+	assert.Equal(0, initConfig(strings.NewReader(answers), bo))
+	assert.Contains(b.String(), "invalid URL 'api.ex.io', expected ie. https://api.ex.io\n")
+	assert.Contains(b.String(), "invalid display 'loud', expected 'verbose' or 'status-code-only'\n")
+	assert.Contains(b.String(), "invalid client cert/key")
+	assert.Contains(b.String(), "Created "+testFile+"\n")
+
+	dat, _ := os.ReadFile(testFile)
+	assert.Contains(string(dat), "# The URL used when a request only passes a path")
+	assert.Contains(string(dat), "\ndisplay: verbose\n")
+
+	gc, err := config.LoadConfiguration(testFile)
+	assert.Nil(err)
+	assert.Equal("https://api.ex.io", gc.URL)
+	assert.Equal(config.HeaderMap{"Accept": {"application/json"}, "X-Team": {"core:platform"}}, gc.Headers)
+	assert.Equal("verbose", gc.Display)
+	assert.Equal(30*time.Second, gc.GetTimeout())
+	assert.Equal(config.ClientAuth{Cert: certFile, Key: keyFile, CA: certFile}, gc.ClientAuth)
+}
+
+func TestInitConfigPKCS12(t *testing.T) {
+	assert := assert.New(t)
+	output.NoColor(true)
+
+	dir := t.TempDir()
+	invalidFile := filepath.Join(dir, "invalid.p12")
+	os.WriteFile(invalidFile, []byte("p12"), 0600)
+	p12File := writeTestP12(t, dir, "secret")
+	testFile := filepath.Join(dir, ".gulp.yml")
+	*configFlag = testFile
+	defer func() { *configFlag = config.DefaultFileName }()
+
+	// The key question is skipped since the PKCS#12 file includes the key,
+	// and the passphrase is asked for when a request is sent
+	answers := strings.Join([]string{"https://api.ex.io", "", "", "", invalidFile, p12File, ""}, "\n") + "\n"
+
+	b := &bytes.Buffer{}
+	bo := &output.BuffOut{Out: b, Err: b}
+	assert.Equal(0, initConfig(strings.NewReader(answers), bo))
+	assert.Contains(b.String(), "invalid client cert/key")
+
+	dat, _ := os.ReadFile(testFile)
+	assert.NotContains(string(dat), "  key:")
+
+	gc, err := config.LoadConfiguration(testFile)
+	assert.Nil(err)
+	assert.Equal(config.ClientAuth{Cert: p12File}, gc.ClientAuth)
+}
+
+func TestInitConfigDefaults(t *testing.T) {
+	assert := assert.New(t)
+	output.NoColor(true)
+
+	testFile := filepath.Join(t.TempDir(), ".gulp.yml")
+	*configFlag = testFile
+	defer func() { *configFlag = config.DefaultFileName }()
+
+	b := &bytes.Buffer{}
+	bo := &output.BuffOut{Out: b, Err: b}
+	assert.Equal(0, initConfig(strings.NewReader(""), bo))
+
+	dat, _ := os.ReadFile(testFile)
+	assert.Contains(string(dat), "# yaml-language-server: $schema="+config.SchemaID+"\n")
+	assert.Contains(string(dat), "# url: https://api.ex.io\n")
+	assert.Contains(string(dat), "# timeout: 30s\n")
+	assert.NotContains(string(dat), "\nclient_auth:")
+
+	_, problems := config.Validate(testFile)
+	assert.Empty(problems)
+}
+
+func TestInitConfigInvalidAtEOF(t *testing.T) {
+	assert := assert.New(t)
+	output.NoColor(true)
+
+	testFile := filepath.Join(t.TempDir(), ".gulp.yml")
+	*configFlag = testFile
+	defer func() { *configFlag = config.DefaultFileName }()
+
+	b := &bytes.Buffer{}
+	bo := &output.BuffOut{Out: b, Err: b}
+	assert.Equal(1, initConfig(strings.NewReader("https://api.ex.io\n\n\n\n/nonexistent/cert.pem"), bo))
+	assert.Contains(b.String(), "could not read '/nonexistent/cert.pem'")
+
+	_, err := os.Stat(testFile)
+	assert.True(os.IsNotExist(err))
+}
+
+func TestInitConfigExists(t *testing.T) {
+	assert := assert.New(t)
+	output.NoColor(true)
+
+	testFile := filepath.Join(t.TempDir(), ".gulp.yml")
+	os.WriteFile(testFile, []byte("url: https://old.ex.io\n"), 0644)
+	*configFlag = testFile
+	defer func() { *configFlag = config.DefaultFileName }()
+
+	b := &bytes.Buffer{}
+	bo := &output.BuffOut{Out: b, Err: b}
+	assert.Equal(1, initConfig(strings.NewReader("https://new.ex.io\n"), bo))
+	assert.Equal("'"+testFile+"' already exists, use -force to overwrite it\n", b.String())
+
+	*forceFlag = true
+	defer func() { *forceFlag = false }()
+
+	b.Reset()
+	assert.Equal(0, initConfig(strings.NewReader("https://new.ex.io\n"), bo))
+
+	dat, _ := os.ReadFile(testFile)
+	assert.Contains(string(dat), "url: \"https://new.ex.io\"\n")
+}
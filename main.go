@@ -1,7 +1,6 @@
 package main
 
 import (
-	"bufio"
 	"bytes"
 	"encoding/json"
 	"flag"
@@ -9,6 +8,7 @@ import (
 	"io"
 	"net/http"
 	"os"
+	"path/filepath"
 	"sort"
 	"strconv"
 	"strings"
@@ -19,6 +19,7 @@ import (
 	"github.com/thoom/gulp/client"
 	"github.com/thoom/gulp/config"
 	"github.com/thoom/gulp/output"
+	"golang.org/x/term"
 )
 
 type stringSlice []string
@@ -36,20 +37,68 @@ func (s *stringSlice) Set(value string) error {
 var (
 	reqHeaders stringSlice
 
+	// queryParams are the -q query parameters
+	queryParams stringSlice
+
+	// formFields are the -F multipart fields and bodyData the -d values
+	formFields stringSlice
+	bodyData   stringSlice
+
+	// authSecrets are masked when the request is displayed
+	authSecrets []string
+	// apiKey is masked where it's sent when the request is displayed
+	apiKey *config.APIKey
+
+	// oauth2Source fetches the bearer token sent with each request when oauth2 is configured
+	oauth2Source *client.OAuth2Source
+
+	// digestAuth answers Digest challenges from the request's host when digest auth is enabled
+	digestAuth *client.DigestAuth
+
+	// session keeps the cookies and -H headers between runs when -session is used
+	session *client.Session
+
+	// credentialHelper adds the headers from the configured credential_helper command to each request
+	credentialHelper *client.CredentialHelper
+
+	// bodyStream is the body sent with -stream, instead of one read into memory
+	bodyStream *client.BodyStream
+	// bodyFile is the file the body was read from with -body-file, -d @file or the configured body, if any
+	bodyFile string
+
+	// requestSigners sign each request when credential_helper, jwt, signing or aws_sigv4 is configured, in order
+	requestSigners []client.RequestSigner
+
 	gulpConfig          = config.New
+	baseConfig          = config.New
 	methodFlag          = flag.String("m", "GET", "The `method` to use: ie. HEAD, GET, POST, PUT, DELETE")
-	configFlag          = flag.String("c", ".gulp.yml", "The `configuration` file to use")
+	configFlag          = flag.String("c", config.DefaultFileName, "The `configuration` file to merge over the global and project configuration")
+	apiKeyFlag          = flag.String("api-key", "", "An API key to send, as `name=value` with an optional @header (default) or @query suffix")
+	digestFlag          = flag.Bool("digest", false, "Use digest auth with the -u credentials instead of basic auth")
+	bearerFlag          = flag.String("bearer", "", "The `token` to send in a bearer Authorization header")
+	bodyFileFlag        = flag.String("body-file", "", "The `file` to send as the request body, its Content-Type is detected from the extension")
+	userFlag            = flag.String("u", "", "The `user:password` to send in a basic Authorization header")
 	clientCert          = flag.String("client-cert", "", "If using client cert auth, the cert to use. MUST be paired with -client-cert-key flag")
 	clientCertKey       = flag.String("client-cert-key", "", "If using client cert auth, the key to use. MUST be paired with -client-cert flag")
+	clientCertPass      = flag.String("client-cert-passphrase", "", "The `passphrase` for a PKCS#12 client cert or an encrypted key, ie. ${CERT_PASSPHRASE} or $(file:path)")
 	clientCA            = flag.String("custom-ca", "", "If using a custom CA certificate, the CA cert file to use for verification")
+	forceFlag           = flag.Bool("force", false, "Overwrite an existing configuration file when running init")
 	insecureFlag        = flag.Bool("insecure", false, "Disable TLS certificate checking")
 	responseOnlyFlag    = flag.Bool("ro", false, "Only display the response body (default)")
 	statusCodeOnlyFlag  = flag.Bool("sco", false, "Only display the response code")
 	verboseFlag         = flag.Bool("v", false, "Display the response body along with various headers")
-	timeoutFlag         = flag.String("timeout", "", "The number of `seconds` to wait before the connection times out "+fmt.Sprintf("(default %d)", config.DefaultTimeout))
+	timeoutFlag         = flag.String("timeout", "", "The `duration` to wait before the request times out, ie. 30s or 1m30s (a plain number is seconds) "+fmt.Sprintf("(default %s)", config.DefaultTimeout))
+	connectTimeoutFlag  = flag.String("connect-timeout", "", "The `duration` to wait for the connection to be established")
+	tlsTimeoutFlag      = flag.String("tls-handshake-timeout", "", "The `duration` to wait for the TLS handshake to complete")
+	headerTimeoutFlag   = flag.String("response-header-timeout", "", "The `duration` to wait for the response headers after the request is sent")
+	netrcFileFlag       = flag.String("netrc-file", "", "The `file` to look up credentials for the host in (default $NETRC or ~/.netrc)")
+	noNetrcFlag         = flag.Bool("no-netrc", false, "Disables looking up credentials for the host in the .netrc file")
 	noColorFlag         = flag.Bool("no-color", false, "Disables color output for the request")
+	profileFlag         = flag.String("p", "", "The configuration `profile` to merge over the base configuration")
 	followRedirectFlag  = flag.Bool("follow-redirect", false, "Enables following 3XX redirects (default)")
 	disableRedirectFlag = flag.Bool("no-redirect", false, "Disables following 3XX redirects")
+	streamFlag          = flag.Bool("stream", false, "Stream the -body-file or stdin into the request with chunked transfer encoding, without loading it into memory")
+	sessionFlag         = flag.String("session", "", "The `name` of a session that keeps the cookies and -H headers between runs")
 	repeatFlag          = flag.Int("repeat-times", 1, "Number of `iteration`s to submit the request")
 	concurrentFlag      = flag.Int("repeat-concurrent", 1, "Number of concurrent `connections` to use")
 	urlFlag             = flag.String("url", "", "The `URL` to use for the request. Alternative to requiring a URL at the end of the command")
@@ -57,15 +106,29 @@ var (
 )
 
 func main() {
-	flag.Var(&reqHeaders, "H", "Set a `request` header")
+	flag.Var(&reqHeaders, "H", "Set a `request` header as 'Name: value', 'Name;' to send it empty, or 'Name:' to remove it. Repeat it to send more values")
+	flag.Var(&queryParams, "q", "Add a query `parameter` as key=value, which is URL encoded. Repeat it to send more values")
+	flag.Var(&formFields, "F", "Add a multipart form `field` as name=value, or name=@file with optional ;type= and ;filename=, ie. avatar=@me.png;type=image/png")
+	flag.Var(&bodyData, "d", "Send `data` as the request body: name=value form data (repeated values are joined with &), inline JSON, or @file")
 	flag.Parse()
 
+	// Handle the subcommands that don't make a request
+	if isCommand(*urlFlag, flag.Args()) {
+		os.Exit(runCommand(flag.Args(), output.Out))
+	}
+
 	// Load the custom configuration
 	loadedConfig, err := config.LoadConfiguration(*configFlag)
 	if err != nil {
 		output.ExitErr("", err)
 	}
 
+	// Merge the selected profile over the base configuration
+	loadedConfig, err = loadedConfig.ApplyProfile(*profileFlag)
+	if err != nil {
+		output.ExitErr("", err)
+	}
+
 	// Set the main config to the one that was loaded
 	gulpConfig = loadedConfig
 
@@ -80,35 +143,174 @@ func main() {
 		os.Exit(0)
 	}
 
-	url, err := client.BuildURL(getPath(*urlFlag, flag.Args()), gulpConfig.URL)
+	// Invalid timeout flags are reported instead of falling back to the configured timeouts
+	if err := checkTimeoutFlags(); err != nil {
+		output.ExitErr("", err)
+	}
+
+	// Show which configuration files were applied
+	printConfigSources(output.Out)
+
+	query, err := client.ParseQuery(queryParams)
 	if err != nil {
 		output.ExitErr("", err)
 	}
 
+	url, err := client.BuildURL(getPath(*urlFlag, flag.Args()), gulpConfig.URL, query, gulpConfig.Query.Values())
+	if err != nil {
+		output.ExitErr("", err)
+	}
+
+	// Apply the host blocks that match the URL. Redirected hops are resolved from the base configuration.
+	baseConfig = gulpConfig
+	gulpConfig = baseConfig.ForHost(client.URLHost(url))
+
+	// A matching host block can disable color output too
+	disableColorOutput()
+
 	// Don't check the TLS bro
 	disableTLSVerify()
 
+	// The passphrase flag can use the same references as the configuration, otherwise it's asked for when needed
+	*clientCertPass, err = config.Interpolate(*clientCertPass)
+	if err != nil {
+		output.ExitErr("", err)
+	}
+	client.PassphrasePrompt = promptPassphrase
+
 	// If the disableRedirectFlag is false and follow redirects is false, then set the flag to true
 	followRedirect := shouldFollowRedirects()
 
+	// Bodies passed as flags are posted unless another method is passed, like curl
+	if (len(formFields) > 0 || len(bodyData) > 0 || *bodyFileFlag != "" || *streamFlag) && !flagSet("m") {
+		*methodFlag = "POST"
+	}
+
 	var body []byte
-	// Don't get the post body if it's a GET/HEAD request
-	if *methodFlag != "GET" && *methodFlag != "HEAD" {
-		var err error
-		body, err = getPostBody(os.Stdin)
+	var contentType string
+	if *streamFlag {
+		bodyStream, err = getBodyStream(os.Stdin)
+		if err == nil {
+			contentType = bodyStream.ContentType()
+		}
+	} else {
+		body, contentType, err = getRequestBody()
+	}
+	if err != nil {
+		output.ExitErr("", err)
+	}
+
+	// A session replays the cookies and -H headers of the earlier runs
+	var sessionHeaders http.Header
+	if *sessionFlag != "" {
+		sessionDir, err := config.SessionDir(*sessionFlag)
 		if err != nil {
 			output.ExitErr("", err)
 		}
+
+		if session, err = client.OpenSession(sessionDir); err != nil {
+			output.ExitErr("", err)
+		}
+		sessionHeaders = session.Headers(client.URLHost(url))
+		session.Remember(client.URLHost(url), reqHeaders)
+	}
+
+	// Compute the credentials from the auth configuration and flags
+	authConfig := gulpConfig.Auth
+	if *digestFlag {
+		authConfig.Digest = config.NewBool(true)
 	}
 
-	// Build request headers
-	headers, err := client.BuildHeaders(reqHeaders, gulpConfig.Headers, body != nil)
+	auth, err := client.BuildAuth(*userFlag, *bearerFlag, *apiKeyFlag, authConfig)
 	if err != nil {
 		output.ExitErr("", err)
 	}
 
-	// Convert the YAML/JSON body if necessary
-	body, err = convertJSONBody(body, headers)
+	if auth.UseDigest() {
+		digestAuth = &client.DigestAuth{Username: auth.Username, Password: auth.Password, Host: client.URLHost(url)}
+	}
+	authSecrets = client.AuthSecrets(auth)
+	apiKey = auth.APIKey
+
+	url, err = client.AuthQuery(url, auth)
+	if err != nil {
+		output.ExitErr("", err)
+	}
+
+	// Credentials passed on the command line (or kept by the session) replace the ones that are computed
+	commandLineAuth := *userFlag != "" || *bearerFlag != "" || headerFlagSet("Authorization") || sessionHeaders.Get("Authorization") != ""
+
+	// The helper's headers are added first so that the signatures can cover them
+	if gulpConfig.CredentialHelper != "" {
+		if credentialHelper, err = client.NewCredentialHelper(gulpConfig.CredentialHelper); err != nil {
+			output.ExitErr("", err)
+		}
+		requestSigners = append(requestSigners, credentialHelper)
+	}
+
+	// A fresh JWT is signed for each request, before the signatures that could cover it
+	jwtAuthorization := gulpConfig.JWT != nil && strings.EqualFold(gulpConfig.JWT.HeaderName(), "Authorization")
+	if gulpConfig.JWT != nil && !(commandLineAuth && jwtAuthorization) {
+		signer, err := createJWTSigner(auth)
+		if err != nil {
+			output.ExitErr("", err)
+		}
+		requestSigners = append(requestSigners, signer)
+	}
+
+	// The HMAC signature is usually sent along with the credentials, so it isn't replaced by them
+	if gulpConfig.Signing != nil {
+		signer, err := client.NewHMACSigner(*gulpConfig.Signing)
+		if err != nil {
+			output.ExitErr("", err)
+		}
+		requestSigners = append(requestSigners, signer)
+	}
+
+	// Credentials passed on the command line replace the OAuth2 token and request signing
+	if !commandLineAuth {
+		if gulpConfig.OAuth2 != nil {
+			oauth2Source, err = createOAuth2Source(auth)
+			if err != nil {
+				output.ExitErr("", err)
+			}
+		}
+
+		// AWS signs every header, so it goes last to include the HMAC signature
+		if gulpConfig.AWSSigV4 != nil {
+			signer, err := createSigV4Signer(auth)
+			if err != nil {
+				output.ExitErr("", err)
+			}
+			requestSigners = append(requestSigners, signer)
+		}
+	}
+
+	// Build request headers, -H headers replace the session headers, which replace the auth headers
+	defaultHeaders := client.WithAuthHeaders(gulpConfig.Headers, auth)
+	for k, v := range sessionHeaders {
+		defaultHeaders[k] = v
+	}
+
+	headers, err := client.BuildHeaders(reqHeaders, defaultHeaders, contentType)
+	if err != nil {
+		output.ExitErr("", err)
+	}
+
+	// Fall back to the .netrc credentials when nothing else sends an Authorization header
+	if headers.Get("Authorization") == "" && !*noNetrcFlag && !auth.UseDigest() && gulpConfig.OAuth2 == nil && gulpConfig.AWSSigV4 == nil && !jwtAuthorization && credentialHelper == nil {
+		if err := applyNetrc(headers, client.URLHost(url)); err != nil {
+			output.ExitErr("", err)
+		}
+	}
+
+	// Convert the YAML/JSON body, or the form payload, if necessary. Streamed bodies are sent as they are.
+	formFile := ""
+	if !contentTypeSet(gulpConfig.Headers) && bodyStream == nil {
+		formFile = formPayloadFile()
+	}
+
+	body, err = encodeBody(body, headers, formFile)
 	if err != nil {
 		output.ExitErr("", err)
 	}
@@ -128,6 +330,12 @@ func main() {
 		}(i, maxChan, &wg)
 	}
 	wg.Wait()
+
+	if session != nil {
+		if err := session.Save(); err != nil {
+			output.ExitErr("", err)
+		}
+	}
 }
 
 func getPath(urlFlag string, args []string) string {
@@ -139,34 +347,235 @@ func getPath(urlFlag string, args []string) string {
 	return path
 }
 
-func processRequest(url string, body []byte, headers map[string]string, iteration int, followRedirect bool) {
+func processRequest(url string, body []byte, headers http.Header, iteration int, followRedirect bool) {
 	var startTimer time.Time
 
-	req, err := client.CreateRequest(*methodFlag, url, body, headers)
+	token := ""
+	if oauth2Source != nil {
+		var err error
+		if token, err = oauth2Source.Token(); err != nil {
+			output.ExitErr("", err)
+		}
+	}
+
+	req, err := newRequest(url, body, withOAuth2Token(headers, token))
 	if err != nil {
 		output.ExitErr("", err)
 	}
 
+	// Sign the final headers and body
+	signRequest(req, body)
+
 	b := &bytes.Buffer{}
 	defer fmt.Print(b)
 	bo := &output.BuffOut{Out: b, Err: b}
 
 	startTimer = time.Now()
-	reqClient, err := client.CreateClient(followRedirect, calculateTimeout(), client.BuildClientAuth(*clientCert, *clientCertKey, *clientCA, gulpConfig.ClientAuth))
+	reqClient, err := client.CreateHostClient(followRedirect, client.URLHost(url), hostOptions)
 	if err != nil {
 		output.ExitErr("Could not create client: ", err)
 	}
 
+	if session != nil {
+		reqClient.Jar = session.Jar
+	}
+
+	// Keep the Digest challenge so the whole exchange can be displayed
+	var challenge *digestChallenge
+	if digestAuth != nil {
+		digest := *digestAuth
+		digest.OnChallenge = func(req *http.Request, resp *http.Response) {
+			challenge = &digestChallenge{header: req.Header.Clone(), status: resp.Status, authenticate: resp.Header.Values("WWW-Authenticate")}
+		}
+		reqClient = digest.Client(reqClient)
+	}
+
 	resp, err := reqClient.Do(req)
 	if err != nil {
 		output.ExitErr("Something unexpected happened", err)
 	}
 
+	// Retry once with a new token if the cached one was rejected, ie. it was revoked before it expired
+	if oauth2Source != nil && resp.StatusCode == http.StatusUnauthorized {
+		resp.Body.Close()
+		if token, err = oauth2Source.Refresh(token); err != nil {
+			output.ExitErr("", err)
+		}
+
+		if req, err = newRequest(url, body, withOAuth2Token(headers, token)); err != nil {
+			output.ExitErr("", err)
+		}
+		signRequest(req, body)
+
+		if resp, err = reqClient.Do(req); err != nil {
+			output.ExitErr("Something unexpected happened", err)
+		}
+	}
+
 	// If we got a request, output what was created
+	if challenge != nil && *verboseFlag {
+		printRequest(iteration, url, challenge.header, req.ContentLength, req.Proto, bo)
+		printDigestChallenge(challenge, bo)
+		iteration = 0
+	}
 	printRequest(iteration, url, resp.Request.Header, req.ContentLength, req.Proto, bo)
 	handleResponse(resp, time.Since(startTimer).Seconds(), bo)
 }
 
+// newRequest creates the request, streaming the body with -stream
+func newRequest(url string, body []byte, headers http.Header) (*http.Request, error) {
+	if bodyStream != nil {
+		return client.CreateStreamRequest(*methodFlag, url, bodyStream, headers)
+	}
+
+	return client.CreateRequest(*methodFlag, url, body, headers)
+}
+
+// createOAuth2Source sets up the OAuth2 token source and fetches the first token,
+// so that a misconfigured client is reported before any requests are sent
+func createOAuth2Source(auth config.Auth) (*client.OAuth2Source, error) {
+	if auth.UseBasic() || auth.Bearer != "" {
+		return nil, fmt.Errorf("oauth2 can't be used with a configured username/password or bearer token")
+	}
+
+	tokenClient, err := client.CreateHostClient(true, client.URLHost(gulpConfig.OAuth2.TokenURL), hostOptions)
+	if err != nil {
+		return nil, fmt.Errorf("could not create oauth2 client: %s", err)
+	}
+
+	source, err := client.NewOAuth2Source(*gulpConfig.OAuth2, tokenClient, config.CacheDir())
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := source.Token(); err != nil {
+		return nil, err
+	}
+
+	return source, nil
+}
+
+// applyNetrc sets a basic Authorization header from the host's .netrc entry, if there is one.
+// The default file is optional, but a file passed with -netrc-file has to exist.
+func applyNetrc(headers http.Header, host string) error {
+	file, optional := *netrcFileFlag, false
+	if file == "" {
+		file, optional = client.DefaultNetrcFile(), true
+	}
+
+	if file == "" {
+		return nil
+	}
+
+	creds, err := client.FindNetrc(file, host, optional)
+	if err != nil || creds == nil {
+		return err
+	}
+
+	netrcAuth := config.Auth{Username: creds.Login, Password: creds.Password}
+	headers.Set("Authorization", client.AuthHeaders(netrcAuth).Get("Authorization"))
+	authSecrets = append(authSecrets, client.AuthSecrets(netrcAuth)...)
+	return nil
+}
+
+// createJWTSigner sets up the JWT signer, making sure that a token sent in the Authorization header
+// doesn't replace the other configured credentials
+func createJWTSigner(auth config.Auth) (*client.JWTSigner, error) {
+	if strings.EqualFold(gulpConfig.JWT.HeaderName(), "Authorization") && (auth.UseBasic() || auth.Bearer != "" || gulpConfig.OAuth2 != nil || gulpConfig.AWSSigV4 != nil) {
+		return nil, fmt.Errorf("jwt can't use the Authorization header along with auth credentials, oauth2 or aws_sigv4, set jwt.header to send it in another header")
+	}
+
+	return client.NewJWTSigner(*gulpConfig.JWT)
+}
+
+// signRequest applies each configured signer to the request
+func signRequest(req *http.Request, body []byte) {
+	for _, signer := range requestSigners {
+		if err := signer.Sign(req, body); err != nil {
+			output.ExitErr("", err)
+		}
+	}
+}
+
+// createSigV4Signer resolves the AWS region and credentials for signing requests
+func createSigV4Signer(auth config.Auth) (*client.SigV4Signer, error) {
+	if auth.UseBasic() || auth.Bearer != "" || oauth2Source != nil {
+		return nil, fmt.Errorf("aws_sigv4 can't be used with a configured username/password, bearer token or oauth2")
+	}
+
+	return client.NewSigV4Signer(*gulpConfig.AWSSigV4)
+}
+
+// withOAuth2Token returns a copy of the headers with the token in the Authorization header.
+// The headers are shared by concurrent requests, so they aren't changed.
+func withOAuth2Token(headers http.Header, token string) http.Header {
+	if token == "" {
+		return headers
+	}
+
+	withToken := headers.Clone()
+	withToken.Set("Authorization", "Bearer "+token)
+
+	return withToken
+}
+
+// flagSet checks whether the flag was passed on the command line
+func flagSet(name string) bool {
+	set := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+
+	return set
+}
+
+// contentTypeSet checks whether the Content-Type was passed with -H or configured
+func contentTypeSet(configHeaders config.HeaderMap) bool {
+	for k := range configHeaders {
+		if strings.EqualFold(k, "Content-Type") {
+			return true
+		}
+	}
+
+	return headerFlagSet("Content-Type")
+}
+
+// headerFlagSet checks whether the header was passed with -H
+func headerFlagSet(name string) bool {
+	for _, header := range reqHeaders {
+		if n, _, _, err := client.ParseHeader(header); err == nil && strings.EqualFold(n, name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// digestChallenge is the request that was challenged and the 401 response
+type digestChallenge struct {
+	header       http.Header
+	status       string
+	authenticate []string
+}
+
+func printDigestChallenge(challenge *digestChallenge, bo *output.BuffOut) {
+	bo.PrintStoplight(fmt.Sprintf("Status: %s (Digest challenge)\n", challenge.status), true)
+	for _, authenticate := range challenge.authenticate {
+		fmt.Fprintln(bo.Out, "WWW-AUTHENTICATE: "+authenticate)
+	}
+	fmt.Fprintln(bo.Out)
+}
+
+func printConfigSources(bo *output.BuffOut) {
+	if !*verboseFlag || len(gulpConfig.Sources) == 0 {
+		return
+	}
+
+	bo.PrintBlock("Configuration\n" + strings.Join(gulpConfig.Sources, "\n"))
+}
+
 func printRequest(iteration int, url string, headers map[string][]string, contentLength int64, protocol string, bo *output.BuffOut) {
 	if !*verboseFlag {
 		if iteration > 0 {
@@ -179,14 +588,30 @@ func printRequest(iteration int, url string, headers map[string][]string, conten
 		bo.PrintHeader(fmt.Sprintf("Iteration #%d", iteration))
 	}
 
-	urlHeader := fmt.Sprintf("%s %s", *methodFlag, url)
+	secrets := authSecrets
+	if credentialHelper != nil {
+		secrets = append(credentialHelper.Secrets(), authSecrets...)
+	}
+
+	var apiKeyQuery, apiKeyHeader string
+	if apiKey != nil && apiKey.In == config.APIKeyInQuery {
+		apiKeyQuery = apiKey.Name
+	} else if apiKey != nil {
+		apiKeyHeader = apiKey.Name
+	}
+
+	urlHeader := fmt.Sprintf("%s %s", *methodFlag, config.MaskSecrets(config.MaskURL(url, apiKeyQuery), secrets))
 	if len(headers) == 0 {
 		bo.PrintHeader(urlHeader)
 		return
 	}
 
 	//Gross hacks bc I can't figure out how to pull these headers automatically
-	headers["Content-Length"] = []string{strconv.FormatInt(contentLength, 10)}
+	if contentLength < 0 {
+		headers["Transfer-Encoding"] = []string{"chunked"}
+	} else {
+		headers["Content-Length"] = []string{strconv.FormatInt(contentLength, 10)}
+	}
 	headers["Accept-Encoding"] = []string{"gzip"}
 
 	block := []string{urlHeader}
@@ -201,8 +626,19 @@ func printRequest(iteration int, url string, headers map[string][]string, conten
 	sort.Strings(mk)
 
 	for _, k := range mk {
+		// A removed User-Agent is left empty so that Go doesn't add its own, and it isn't sent
+		if strings.EqualFold(k, "User-Agent") && strings.Join(headers[k], "") == "" {
+			continue
+		}
+
 		for _, kk := range headers[k] {
-			block = append(block, strings.ToUpper(k)+": "+kk)
+			// Digest responses are hashes, so they're shown to make the exchange easier to follow
+			value := config.MaskSecrets(kk, secrets)
+			sensitive := config.IsSensitiveHeader(k) || (apiKeyHeader != "" && strings.EqualFold(k, apiKeyHeader))
+			if sensitive && !strings.HasPrefix(kk, "Digest ") {
+				value = config.MaskSecret(kk)
+			}
+			block = append(block, strings.ToUpper(k)+": "+value)
 		}
 	}
 	bo.PrintBlock(strings.Join(block, "\n"))
@@ -256,39 +692,158 @@ func handleResponse(resp *http.Response, duration float64, bo *output.BuffOut) {
 	fmt.Fprintln(bo.Out, string(body))
 }
 
-func getPostBody(input *os.File) ([]byte, error) {
-	stat, _ := input.Stat()
-
-	if (stat.Mode() & os.ModeCharDevice) == 0 {
-		scanner := bufio.NewScanner(input)
-		var stdin []byte
-		first := true
-		for scanner.Scan() {
-			if first {
-				first = false
-			} else {
-				stdin = append(stdin, []byte("\n")...)
+// getRequestBody builds the body from the -F fields, the -d values or the -body-file, falling back to stdin
+// and then the configured body. It returns the body along with its default Content-Type.
+func getRequestBody() ([]byte, string, error) {
+	sources := 0
+	for _, set := range []bool{len(formFields) > 0, len(bodyData) > 0, *bodyFileFlag != ""} {
+		if set {
+			sources++
+		}
+	}
+	if sources > 1 {
+		return nil, "", fmt.Errorf("only one of -F, -d or -body-file can be used")
+	}
+
+	switch {
+	case len(formFields) > 0:
+		fields := make([]client.FormField, len(formFields))
+		for i, f := range formFields {
+			field, err := client.ParseFormField(f)
+			if err != nil {
+				return nil, "", err
 			}
+			fields[i] = field
+		}
 
-			stdin = append(stdin, scanner.Bytes()...)
+		return client.EncodeMultipart(fields)
+	case len(bodyData) > 0:
+		if len(bodyData) == 1 && strings.HasPrefix(bodyData[0], "@") {
+			bodyFile = bodyData[0][1:]
 		}
+		return client.DataBody(bodyData)
+	case *bodyFileFlag != "":
+		bodyFile = *bodyFileFlag
+		return client.ReadBodyFile(bodyFile)
+	}
+
+	// Don't get the post body if it's a GET/HEAD request
+	if *methodFlag == "GET" || *methodFlag == "HEAD" {
+		return nil, "", nil
+	}
 
-		if err := scanner.Err(); err != nil {
-			return nil, fmt.Errorf("reading standard input: %s", err)
+	body, err := getPostBody(os.Stdin)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if body == nil && gulpConfig.Body != "" {
+		// The configured body is either a @file or inline JSON/YAML
+		if path, ok := strings.CutPrefix(gulpConfig.Body, "@"); ok {
+			bodyFile = strings.TrimSpace(path)
+			return client.ReadBodyFile(bodyFile)
 		}
+		body = []byte(gulpConfig.Body)
+	}
 
-		return stdin, nil
+	if body == nil {
+		return nil, "", nil
 	}
 
-	return nil, nil
+	return body, "application/json", nil
 }
 
-func convertJSONBody(body []byte, headers map[string]string) ([]byte, error) {
+// getBodyStream streams the -body-file, or stdin when it's piped
+func getBodyStream(input *os.File) (*client.BodyStream, error) {
+	if len(formFields) > 0 || len(bodyData) > 0 {
+		return nil, fmt.Errorf("-stream can't be used with -F or -d, pass the file with -body-file")
+	}
+
+	// The signatures cover the whole body, which would have to be read into memory
+	if gulpConfig.Signing != nil || gulpConfig.AWSSigV4 != nil {
+		return nil, fmt.Errorf("-stream can't be used with signing or aws_sigv4")
+	}
+
+	if *bodyFileFlag != "" {
+		return client.NewFileStream(*bodyFileFlag)
+	}
+
+	if !isPiped(input) {
+		return nil, fmt.Errorf("-stream needs a -body-file or a body piped to stdin")
+	}
+
+	if *repeatFlag > 1 {
+		return nil, fmt.Errorf("stdin can only be streamed once, pass the file with -body-file to repeat the request")
+	}
+
+	return client.NewStdinStream(input), nil
+}
+
+// getPostBody reads the whole input as it is, so that binary bodies aren't changed
+func getPostBody(input *os.File) ([]byte, error) {
+	if !isPiped(input) {
+		return nil, nil
+	}
+
+	body, err := io.ReadAll(input)
+	if err != nil {
+		return nil, fmt.Errorf("reading standard input: %s", err)
+	}
+
+	if len(body) == 0 {
+		return nil, nil
+	}
+
+	return body, nil
+}
+
+// isPiped checks whether the input is a pipe or file rather than a terminal
+func isPiped(input *os.File) bool {
+	stat, err := input.Stat()
+	return err == nil && (stat.Mode()&os.ModeCharDevice) == 0
+}
+
+// formPayloadFile returns the YAML file the body was read from with -body-file, -d @file or the configured body,
+// or "" if there isn't one. Only those can be form payloads, so that a body from stdin is never turned into a form
+// or made to upload local files.
+func formPayloadFile() string {
+	if ext := strings.ToLower(filepath.Ext(bodyFile)); ext == ".yml" || ext == ".yaml" {
+		return bodyFile
+	}
+
+	return ""
+}
+
+// encodeBody converts a YAML/JSON payload to JSON. When the body was read from formFile, a payload with only
+// a form or multipart key is encoded as that kind of form instead (see client.DecodeFormPayload), with the
+// files it uploads relative to formFile.
+func encodeBody(body []byte, headers http.Header, formFile string) ([]byte, error) {
 	// Determine if we should convert the body to JSON
-	if !strings.Contains(headers["CONTENT-TYPE"], "json") {
+	if !strings.Contains(headers.Get("Content-Type"), "json") {
 		return body, nil
 	}
 
+	if formFile != "" {
+		kind, fields, err := client.DecodeFormPayload(body, filepath.Dir(formFile))
+		if err != nil {
+			return nil, err
+		}
+
+		switch kind {
+		case "form":
+			headers.Set("Content-Type", client.FormContentType)
+			return client.EncodeForm(fields), nil
+		case "multipart":
+			multipartBody, contentType, err := client.EncodeMultipart(fields)
+			if err != nil {
+				return nil, err
+			}
+
+			headers.Set("Content-Type", contentType)
+			return multipartBody, nil
+		}
+	}
+
 	j, err := yaml.YAMLToJSON(body)
 	if err != nil {
 		return nil, fmt.Errorf("could not parse post body: %s", err)
@@ -297,6 +852,29 @@ func convertJSONBody(body []byte, headers map[string]string) ([]byte, error) {
 	return j, nil
 }
 
+// promptPassphrase asks for the passphrase of a client cert or key on the terminal, without echoing it.
+// The terminal is used directly since stdin can be the request body.
+func promptPassphrase(name string) (string, error) {
+	in, out := os.Stdin, os.Stderr
+	if tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0); err == nil {
+		defer tty.Close()
+		in, out = tty, tty
+	}
+
+	if !term.IsTerminal(int(in.Fd())) {
+		return "", fmt.Errorf("%w for '%s', set client_auth.passphrase or -client-cert-passphrase", client.ErrPassphraseRequired, name)
+	}
+
+	fmt.Fprintf(out, "Passphrase for %s: ", name)
+	passphrase, err := term.ReadPassword(int(in.Fd()))
+	fmt.Fprintln(out)
+	if err != nil {
+		return "", fmt.Errorf("could not read the passphrase: %s", err)
+	}
+
+	return string(passphrase), nil
+}
+
 func disableColorOutput() {
 	if *noColorFlag || !gulpConfig.UseColor() {
 		output.NoColor(true)
@@ -308,21 +886,80 @@ func disableTLSVerify() {
 		if *verboseFlag {
 			output.Out.PrintWarning("TLS checking is disabled for this request")
 		}
+	}
+
+	// The config setting is applied per host (see hostOptions)
+	if *insecureFlag {
 		client.DisableTLSVerification()
 	}
 }
 
-func calculateTimeout() int {
-	if *timeoutFlag == "" {
-		return gulpConfig.GetTimeout()
+// hostOptions resolves the settings for a host from the base configuration and its matching host blocks.
+// The CLI flags take precedence over both.
+func hostOptions(host string) client.HostOptions {
+	hostConfig := baseConfig.ForHost(host)
+
+	configHeaders := http.Header{}
+	for k, v := range hostConfig.Headers {
+		configHeaders[http.CanonicalHeaderKey(k)] = v
 	}
 
-	i, err := strconv.Atoi(*timeoutFlag)
-	if err != nil {
-		return gulpConfig.GetTimeout()
+	// The -H headers were already checked when the request headers were built
+	headers, _ := client.BuildHeaders(reqHeaders, configHeaders, "")
+
+	var hostHeaders []string
+	for _, h := range baseConfig.MatchingHosts(host) {
+		for k := range h.Headers {
+			hostHeaders = append(hostHeaders, http.CanonicalHeaderKey(k))
+		}
+	}
+
+	return client.HostOptions{
+		ClientAuth:  client.BuildClientAuth(*clientCert, *clientCertKey, *clientCertPass, *clientCA, hostConfig.ClientAuth),
+		Timeouts:    calculateTimeouts(hostConfig),
+		VerifyTLS:   !*insecureFlag && hostConfig.VerifyTLS(),
+		Headers:     headers,
+		HostHeaders: hostHeaders,
+	}
+}
+
+func calculateTimeouts(cfg *config.Config) config.Timeouts {
+	timeouts := cfg.GetTimeouts()
+	timeouts.Overall = flagDuration(*timeoutFlag, timeouts.Overall)
+	timeouts.Connect = flagDuration(*connectTimeoutFlag, timeouts.Connect)
+	timeouts.TLSHandshake = flagDuration(*tlsTimeoutFlag, timeouts.TLSHandshake)
+	timeouts.ResponseHeader = flagDuration(*headerTimeoutFlag, timeouts.ResponseHeader)
+
+	return timeouts
+}
+
+// checkTimeoutFlags returns an error for the first timeout flag that isn't a valid duration
+func checkTimeoutFlags() error {
+	flags := []struct{ name, value string }{
+		{"timeout", *timeoutFlag},
+		{"connect-timeout", *connectTimeoutFlag},
+		{"tls-handshake-timeout", *tlsTimeoutFlag},
+		{"response-header-timeout", *headerTimeoutFlag},
+	}
+
+	for _, f := range flags {
+		if _, err := config.ParseTimeout(f.value); err != nil {
+			return fmt.Errorf("invalid -%s '%s', expected a duration like 30s or 1m30s", f.name, f.value)
+		}
+	}
+
+	return nil
+}
+
+// flagDuration parses a timeout flag, falling back to the configured value if the flag isn't set.
+// The flags are checked by checkTimeoutFlags before any request is made.
+func flagDuration(value string, configured time.Duration) time.Duration {
+	if value == "" {
+		return configured
 	}
 
-	return i
+	d, _ := config.ParseTimeout(value)
+	return d
 }
 
 func shouldFollowRedirects() bool {